@@ -0,0 +1,159 @@
+// Package card provides offline checks for payment card details: Luhn validation of the
+// card number, expiry and CVV checks, and brand detection from the card's IIN range.
+//
+// These checks let a checkout give customers instant feedback and avoid a round-trip
+// to PayTR for details that can never be accepted.
+package card
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Brand is a card scheme, using the card_type values PayTR expects.
+type Brand string
+
+const (
+	BrandUnknown Brand = ""
+	BrandVisa    Brand = "visa"
+	BrandMaster  Brand = "master"
+	BrandAmex    Brand = "amex"
+	BrandTroy    Brand = "troy"
+)
+
+var (
+	ErrInvalidNumber = errors.New("card number is invalid")
+	ErrInvalidExpiry = errors.New("card expiry date is invalid")
+	ErrExpired       = errors.New("card has expired")
+	ErrInvalidCVV    = errors.New("card CVV is invalid")
+)
+
+// Normalize removes the spaces and dashes customers commonly type in card numbers.
+func Normalize(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// Luhn reports whether number consists of 12 to 19 digits and passes the Luhn checksum.
+func Luhn(number string) bool {
+	number = Normalize(number)
+	if len(number) < 12 || len(number) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// DetectBrand infers the card brand from the IIN (leading digits) of number.
+func DetectBrand(number string) Brand {
+	number = Normalize(number)
+
+	switch {
+	case strings.HasPrefix(number, "9792"):
+		return BrandTroy
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return BrandAmex
+	case strings.HasPrefix(number, "4"):
+		return BrandVisa
+	}
+
+	if prefix := leadingInt(number, 2); prefix >= 51 && prefix <= 55 {
+		return BrandMaster
+	}
+	if prefix := leadingInt(number, 4); prefix >= 2221 && prefix <= 2720 {
+		return BrandMaster
+	}
+	return BrandUnknown
+}
+
+// CVVLength returns the number of CVV digits cards of the brand have.
+func CVVLength(brand Brand) int {
+	if brand == BrandAmex {
+		return 4
+	}
+	return 3
+}
+
+// CheckCVV checks that cvv has the length expected for the brand.
+func CheckCVV(cvv string, brand Brand) error {
+	if len(cvv) != CVVLength(brand) || !isDigits(cvv) {
+		return ErrInvalidCVV
+	}
+	return nil
+}
+
+// CheckExpiry checks that month and year form a valid expiry date that has not passed at now.
+// The year may have two ("30") or four ("2030") digits. A card is valid until the end of its expiry month.
+func CheckExpiry(month, year string, now time.Time) error {
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 {
+		return ErrInvalidExpiry
+	}
+
+	if !isDigits(year) || (len(year) != 2 && len(year) != 4) {
+		return ErrInvalidExpiry
+	}
+	y, _ := strconv.Atoi(year)
+	if len(year) == 2 {
+		y += 2000
+	}
+
+	endOfMonth := time.Date(y, time.Month(m)+1, 1, 0, 0, 0, 0, now.Location())
+	if !now.Before(endOfMonth) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Validate runs all offline checks on the card details and returns every problem found.
+func Validate(number, month, year, cvv string, now time.Time) []error {
+	var errs []error
+	if !Luhn(number) {
+		errs = append(errs, ErrInvalidNumber)
+	}
+	if err := CheckExpiry(month, year, now); err != nil {
+		errs = append(errs, err)
+	}
+	if err := CheckCVV(cvv, DetectBrand(number)); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func leadingInt(s string, n int) int {
+	if len(s) < n {
+		return -1
+	}
+	v, err := strconv.Atoi(s[:n])
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
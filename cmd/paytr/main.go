@@ -0,0 +1,355 @@
+// Command paytr checks and refunds PayTR orders, lists transactions, BINs and saved
+// cards from the command line.
+//
+// Usage:
+//
+//	paytr [-config file] [-json] <command> [arguments]
+//
+// Commands:
+//
+//	status <merchant_oid>                     show the status of an order
+//	refund [-yes] [-reference no] <merchant_oid> <amount>
+//	                                          refund an order in part or in full; refunds
+//	                                          over the refundable amount are rejected; the
+//	                                          amount is in the currency of the order
+//	transactions -from date [-to date] [-format table|csv|jsonl]
+//	                                          list the transactions of a period;
+//	                                          -json lists them as JSON lines
+//	bin <number>                              show the details of a card BIN
+//	cards list <utoken>                       list the saved cards of a user
+//	cards delete <utoken> <ctoken>            delete a saved card
+//
+// Credentials are read from the JSON file given with -config or in PAYTR_CONFIG,
+// and from the PAYTR_MERCHANT_ID, PAYTR_MERCHANT_KEY, PAYTR_MERCHANT_SALT and
+// PAYTR_BASE_URL environment variables, which take precedence.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/streamerd/paytr-go/config"
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+	"github.com/streamerd/paytr-go/report"
+)
+
+const usage = `usage: paytr [-config file] [-json] <command> [arguments]
+
+commands:
+  status <merchant_oid>
+  refund [-yes] [-reference no] <merchant_oid> <amount>
+  transactions -from 2006-01-02 [-to 2006-01-02] [-format table|csv|jsonl]
+  bin <number>
+  cards list <utoken>
+  cards delete <utoken> <ctoken>
+`
+
+// errUsage reports invalid arguments; the usage is printed instead of the error.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// cli holds what the commands share.
+type cli struct {
+	svc    payment.Service
+	json   bool
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("paytr", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	configFile := flags.String("config", os.Getenv("PAYTR_CONFIG"), "JSON file with the merchant credentials")
+	asJSON := flags.Bool("json", false, "print JSON instead of tables")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "paytr: %v\n", err)
+		return 1
+	}
+
+	svc := payment.NewService(cfg)
+	c := &cli{svc: svc, json: *asJSON, stdin: stdin, stdout: stdout}
+	command, rest := flags.Arg(0), flags.Args()[1:]
+
+	switch command {
+	case "status":
+		err = c.status(ctx, rest)
+	case "refund":
+		err = c.refund(ctx, rest)
+	case "transactions":
+		err = c.transactions(ctx, rest, stderr)
+	case "bin":
+		err = c.bin(ctx, rest)
+	case "cards":
+		err = c.cards(ctx, rest)
+	default:
+		err = errUsage
+	}
+
+	if errors.Is(err, errUsage) {
+		if err != errUsage {
+			fmt.Fprintf(stderr, "paytr: %v\n", err)
+		}
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "paytr: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func loadConfig(path string) (config.PayTRConfig, error) {
+	var cfg config.PayTRConfig
+	if path != "" {
+		var err error
+		if cfg, err = config.LoadFile(path); err != nil {
+			return cfg, err
+		}
+	}
+	cfg = cfg.FromEnv()
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("%v (set them in a config file or the PAYTR_* environment variables)", err)
+	}
+	return cfg, nil
+}
+
+func (c *cli) status(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	resp, err := c.svc.MerchantStatusInquiryContext(ctx, domain.StatusInquiryRequest{MerchantOid: args[0]})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+
+	v, err := resp.View()
+	if err != nil {
+		return err
+	}
+	refunds, err := resp.RefundSummary(args[0])
+	if err != nil {
+		return err
+	}
+	return c.printFields([][2]string{
+		{"Order", args[0]},
+		{"Status", v.Status},
+		{"Amount", v.Amount.String() + " " + v.Currency},
+		{"Total", v.Total.String() + " " + v.Currency},
+		{"Net", v.NetAmount.String() + " " + v.Currency},
+		{"Paid at", formatTime(v.PaidAt)},
+		{"Installments", fmt.Sprint(v.Installments)},
+		{"Card", strings.TrimSpace(v.CardBrand + " " + v.MaskedPan)},
+		{"Refunded", fmt.Sprintf("%s %s (%d refunds)", refunds.Refunded, v.Currency, len(refunds.Refunds))},
+		{"Refundable", refunds.Remaining.String() + " " + v.Currency},
+		{"Test mode", fmt.Sprint(v.TestMode)},
+	})
+}
+
+func (c *cli) refund(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("refund", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	yes := flags.Bool("yes", false, "do not ask for confirmation")
+	reference := flags.String("reference", "", "reference number of the refund")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+
+	// The amount is in the currency the order was paid in.
+	merchantOid := flags.Arg(0)
+	refunds, err := c.svc.GetRefundsContext(ctx, merchantOid)
+	if err != nil {
+		return err
+	}
+	currency := refunds.Paid.Currency
+	amount, err := domain.ParseMoney(flags.Arg(1), currency)
+	if err != nil {
+		return err
+	}
+
+	if !*yes {
+		fmt.Fprintf(c.stdout, "Order %s: %s %s paid, %s %s refundable.\n", merchantOid, refunds.Paid, currency, refunds.Remaining, currency)
+		fmt.Fprintf(c.stdout, "Refund %s %s of order %s? [y/N] ", amount, currency, merchantOid)
+		answer, _ := bufio.NewReader(c.stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return errors.New("refund cancelled")
+		}
+	}
+
+	resp, err := c.svc.RefundPaymentContext(ctx, domain.RefundRequest{
+		MerchantOid:  merchantOid,
+		ReturnAmount: amount,
+		ReferenceNo:  *reference,
+	})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	fmt.Fprintf(c.stdout, "Refunded %s %s of order %s (reference %v)\n", amount, currency, merchantOid, resp.Data["reference_no"])
+	return nil
+}
+
+func (c *cli) transactions(ctx context.Context, args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("transactions", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	fromFlag := flags.String("from", "", "first day, e.g. 2024-05-01")
+	toFlag := flags.String("to", "", "last day, inclusive; defaults to -from")
+	format := flags.String("format", "table", "table, csv or jsonl")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *fromFlag == "" {
+		return errUsage
+	}
+	if *toFlag == "" {
+		*toFlag = *fromFlag
+	}
+	// -json prints JSON lines; any other format given explicitly contradicts it.
+	formatSet := false
+	flags.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+	if c.json && formatSet && *format != "jsonl" {
+		return fmt.Errorf("%w: -json cannot be combined with -format %s", errUsage, *format)
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", *fromFlag, domain.PayTRLocation)
+	if err != nil {
+		return fmt.Errorf("invalid -from date: %v", err)
+	}
+	to, err := time.ParseInLocation("2006-01-02", *toFlag, domain.PayTRLocation)
+	if err != nil {
+		return fmt.Errorf("invalid -to date: %v", err)
+	}
+	transactions := c.svc.GetTransactionDetailsRangeContext(ctx, from, to.AddDate(0, 0, 1))
+
+	switch {
+	case c.json || *format == "jsonl":
+		return report.WriteTransactionSeq(c.stdout, report.FormatJSONL, report.Options{}, transactions)
+	case *format == "csv":
+		return report.WriteTransactionSeq(c.stdout, report.FormatCSV, report.Options{}, transactions)
+	case *format != "table":
+		return errUsage
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tTYPE\tORDER\tAMOUNT\tFEE\tNET\tINST.\tCARD")
+	for t, err := range transactions {
+		if err != nil {
+			w.Flush()
+			return err
+		}
+		v, err := t.View()
+		if err != nil {
+			fmt.Fprintf(stderr, "paytr: skipping transaction %s: %v\n", t.SiparisNo, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", formatTime(v.OccurredAt), v.Kind, v.MerchantOid,
+			v.Amount, v.FeeAmount, v.NetAmount, v.Installments, v.CardBrand)
+	}
+	return w.Flush()
+}
+
+func (c *cli) bin(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	details, err := c.svc.GetBinDetailsContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(details)
+	}
+	return c.printFields([][2]string{
+		{"BIN", details.BIN},
+		{"Bank", details.Bank},
+		{"Brand", details.Brand},
+		{"Type", details.CardType},
+		{"Family", details.CardFamily},
+		{"Business", fmt.Sprint(details.BusinessCard)},
+		{"Non-3D allowed", fmt.Sprint(details.AllowNon3D)},
+		{"Installments", fmt.Sprint(details.InstallmentAllowed)},
+	})
+}
+
+func (c *cli) cards(ctx context.Context, args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "list":
+		cards, err := c.svc.GetSavedCardsContext(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(cards)
+		}
+		w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "CTOKEN\tCARD\tEXPIRY\tBANK\tTYPE\tOWNER")
+		for _, card := range cards {
+			fmt.Fprintf(w, "%s\t%s *%s\t%s\t%s\t%s\t%s\n", card.CToken, card.Schema, card.LastFour,
+				card.ExpiryDate, card.Bank, card.CardType, card.OwnerName)
+		}
+		return w.Flush()
+
+	case len(args) == 3 && args[0] == "delete":
+		resp, err := c.svc.DeleteSavedCardContext(ctx, args[1], args[2])
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(resp)
+		}
+		fmt.Fprintf(c.stdout, "Deleted card %s\n", args[2])
+		return nil
+	}
+	return errUsage
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (c *cli) printFields(fields [][2]string) error {
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s:\t%s\n", f[0], f[1])
+	}
+	return w.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(domain.PayTRLocation).Format(domain.ReportTimeFormat)
+}
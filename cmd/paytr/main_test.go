@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamerd/paytr-go/config"
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+	"github.com/streamerd/paytr-go/paytrtest"
+)
+
+// setupCLI starts a PayTR simulator, points the PAYTR_* environment variables at it
+// and pays order1 with 100.00 USD.
+func setupCLI(t *testing.T) *paytrtest.Server {
+	t.Helper()
+
+	srv := paytrtest.NewServer(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+	})
+	t.Cleanup(srv.Close)
+
+	cfg := srv.Config()
+	t.Setenv("PAYTR_CONFIG", "")
+	t.Setenv(config.EnvMerchantID, cfg.MerchantID)
+	t.Setenv(config.EnvMerchantKey, cfg.MerchantKey)
+	t.Setenv(config.EnvMerchantSalt, cfg.MerchantSalt)
+	t.Setenv(config.EnvBaseURL, cfg.BaseURL)
+
+	amount := domain.NewMoney(10000, "USD")
+	_, err := payment.NewService(cfg).NewCardPayment(domain.NewCardPaymentRequest{
+		CommonPaymentRequest: domain.CommonPaymentRequest{
+			UserIP:        "127.0.0.1",
+			MerchantOid:   "order1",
+			Email:         "test@example.com",
+			PaymentAmount: amount,
+			UserBasket:    domain.NewBasket().Add("Product", amount, 1),
+			PaymentType:   "card",
+			Currency:      "USD",
+			TestMode:      "1",
+			NonThreeD:     "1",
+		},
+		CardOwner:   "John Doe",
+		CardNumber:  "4111111111111111",
+		ExpiryMonth: "12",
+		ExpiryYear:  strconv.Itoa(time.Now().Year() + 5),
+		CVV:         "123",
+	})
+	if err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+	return srv
+}
+
+// runCLI runs the command with args and stdin, and returns its exit code and output.
+func runCLI(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	setupCLI(t)
+
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"status"},
+		{"refund", "order1"},
+		{"transactions"},
+		{"transactions", "-from", "2024-05-01", "-format", "xml"},
+		{"-json", "transactions", "-from", "2024-05-01", "-format", "csv"},
+		{"cards", "list"},
+	} {
+		code, _, stderr := runCLI(args, "")
+		if code != 2 || !strings.Contains(stderr, "usage: paytr") {
+			t.Errorf("%v: expected exit code 2 and the usage, got %d %q", args, code, stderr)
+		}
+	}
+
+	_, _, stderr := runCLI([]string{"-json", "transactions", "-from", "2024-05-01", "-format", "csv"}, "")
+	if !strings.Contains(stderr, "-json cannot be combined with -format csv") {
+		t.Errorf("expected the conflicting flags to be named, got %q", stderr)
+	}
+
+	t.Setenv(config.EnvMerchantKey, "")
+	if code, _, stderr := runCLI([]string{"status", "order1"}, ""); code != 1 || !strings.Contains(stderr, "merchant_key") {
+		t.Errorf("expected missing credentials to be reported, got %d %q", code, stderr)
+	}
+}
+
+func TestRunRefund(t *testing.T) {
+	srv := setupCLI(t)
+
+	code, stdout, stderr := runCLI([]string{"refund", "order1", "30"}, "n\n")
+	if code != 1 || !strings.Contains(stderr, "refund cancelled") {
+		t.Errorf("expected the refund to be cancelled, got %d %q", code, stderr)
+	}
+	if !strings.Contains(stdout, "Order order1: 100.00 USD paid, 100.00 USD refundable.") ||
+		!strings.Contains(stdout, "Refund 30.00 USD of order order1? [y/N]") {
+		t.Errorf("unexpected prompt %q", stdout)
+	}
+	if order, _ := srv.Order("order1"); len(order.Refunds) != 0 {
+		t.Errorf("expected no refund to be sent, got %+v", order.Refunds)
+	}
+
+	code, stdout, stderr = runCLI([]string{"refund", "-reference", "ref1", "order1", "30"}, "yes\n")
+	if code != 0 || !strings.Contains(stdout, "Refunded 30.00 USD of order order1 (reference ref1)") {
+		t.Errorf("expected the refund to be made, got %d %q %q", code, stdout, stderr)
+	}
+
+	code, stdout, _ = runCLI([]string{"refund", "-yes", "order1", "80"}, "")
+	if code != 1 || strings.Contains(stdout, "[y/N]") {
+		t.Errorf("expected a refund over the refundable amount to fail without a prompt, got %d %q", code, stdout)
+	}
+
+	code, stdout, _ = runCLI([]string{"status", "order1"}, "")
+	if code != 0 || !strings.Contains(stdout, "30.00 USD (1 refunds)") || !strings.Contains(stdout, "70.00 USD") {
+		t.Errorf("unexpected status %d %q", code, stdout)
+	}
+}
+
+func TestRunTransactions(t *testing.T) {
+	setupCLI(t)
+	today := time.Now().In(domain.PayTRLocation).Format("2006-01-02")
+
+	for _, tc := range []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"transactions", "-from", today}, []string{"DATE", "ORDER", "order1", "100.00"}},
+		{[]string{"transactions", "-from", today, "-format", "csv"}, []string{"Order No", "sale,order1,", ",USD,"}},
+		{[]string{"transactions", "-from", today, "-format", "jsonl"}, []string{`"merchant_oid":"order1"`}},
+		{[]string{"-json", "transactions", "-from", today}, []string{`"merchant_oid":"order1"`}},
+	} {
+		code, stdout, stderr := runCLI(tc.args, "")
+		if code != 0 {
+			t.Errorf("%v: exit code %d: %s", tc.args, code, stderr)
+			continue
+		}
+		for _, s := range tc.expected {
+			if !strings.Contains(stdout, s) {
+				t.Errorf("%v: expected %q in %q", tc.args, s, stdout)
+			}
+		}
+	}
+}
@@ -1,9 +1,115 @@
 package config
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
 // PayTRConfig holds the configuration necessary to interact with PayTR's API,
 // including the merchant's credentials.
 type PayTRConfig struct {
-	MerchantID   string
-	MerchantKey  string
-	MerchantSalt string
+	MerchantID   string `json:"merchant_id"`
+	MerchantKey  string `json:"merchant_key"`
+	MerchantSalt string `json:"merchant_salt"`
+
+	// BaseURL replaces domain.PayTRBaseURL, e.g. to reach a local stand-in,
+	// an egress proxy or a staging mirror. It is optional.
+	BaseURL string `json:"base_url,omitempty"`
+
+	// Endpoints overrides the path of individual endpoints. Endpoints that are
+	// not listed keep their default path.
+	Endpoints map[domain.Endpoint]string `json:"endpoints,omitempty"`
+
+	// Timeout bounds each request whose context has no deadline; DefaultTimeout is
+	// used when it is zero. A context deadline always takes precedence, longer or shorter.
+	Timeout time.Duration `json:"-"`
+}
+
+// DefaultTimeout is the time a request may take when neither its context nor
+// PayTRConfig.Timeout sets a limit.
+const DefaultTimeout = 10 * time.Second
+
+// Environment variables read by FromEnv.
+const (
+	EnvMerchantID   = "PAYTR_MERCHANT_ID"
+	EnvMerchantKey  = "PAYTR_MERCHANT_KEY"
+	EnvMerchantSalt = "PAYTR_MERCHANT_SALT"
+	EnvBaseURL      = "PAYTR_BASE_URL"
+)
+
+// LoadFile reads a configuration from a JSON file such as
+// {"merchant_id": "...", "merchant_key": "...", "merchant_salt": "..."}.
+func LoadFile(path string) (PayTRConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PayTRConfig{}, err
+	}
+
+	var c PayTRConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return PayTRConfig{}, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// FromEnv returns c with the fields set in the PAYTR_* environment variables replaced.
+func (c PayTRConfig) FromEnv() PayTRConfig {
+	for env, field := range map[string]*string{
+		EnvMerchantID:   &c.MerchantID,
+		EnvMerchantKey:  &c.MerchantKey,
+		EnvMerchantSalt: &c.MerchantSalt,
+		EnvBaseURL:      &c.BaseURL,
+	} {
+		if value := os.Getenv(env); value != "" {
+			*field = value
+		}
+	}
+	return c
+}
+
+// Validate reports missing merchant credentials.
+func (c PayTRConfig) Validate() error {
+	var missing []string
+	if c.MerchantID == "" {
+		missing = append(missing, "merchant_id")
+	}
+	if c.MerchantKey == "" {
+		missing = append(missing, "merchant_key")
+	}
+	if c.MerchantSalt == "" {
+		missing = append(missing, "merchant_salt")
+	}
+	if len(missing) > 0 {
+		return errors.New("missing " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// RequestTimeout returns Timeout, or DefaultTimeout if it is not set.
+func (c PayTRConfig) RequestTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+// URL returns the full URL of the endpoint, applying BaseURL and any path override.
+func (c PayTRConfig) URL(endpoint domain.Endpoint) string {
+	base := c.BaseURL
+	if base == "" {
+		base = domain.PayTRBaseURL
+	}
+
+	path := string(endpoint)
+	if override, ok := c.Endpoints[endpoint]; ok {
+		path = override
+	}
+
+	return strings.TrimRight(base, "/") + path
 }
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BasketItem is a single line of a user basket.
+// VATRate and Category are kept for the merchant's own bookkeeping; PayTR's
+// user_basket format only carries the name, unit price and quantity.
+type BasketItem struct {
+	Name      string
+	UnitPrice Money
+	Quantity  int
+	VATRate   int
+	Category  string
+}
+
+// Total returns the unit price multiplied by the quantity.
+func (i BasketItem) Total() Money {
+	return i.UnitPrice.Mul(int64(i.Quantity))
+}
+
+// Basket is the list of items sent as user_basket with a payment request.
+type Basket []BasketItem
+
+// NewBasket returns a basket holding the given items.
+func NewBasket(items ...BasketItem) Basket {
+	return Basket(items)
+}
+
+// Add returns the basket with a new item appended.
+func (b Basket) Add(name string, unitPrice Money, quantity int) Basket {
+	return append(b, BasketItem{Name: name, UnitPrice: unitPrice, Quantity: quantity})
+}
+
+// Total returns the sum of all line totals.
+func (b Basket) Total() Money {
+	var total Money
+	for i, item := range b {
+		if i == 0 {
+			total.Currency = item.UnitPrice.Currency
+		}
+		total = total.Add(item.Total())
+	}
+	return total
+}
+
+// Validate checks that the basket is well formed and that its total equals amount.
+// All problems found are reported together. The service validates the basket of every
+// payment and iFrame token request before it is sent.
+func (b Basket) Validate(amount Money) error {
+	if len(b) == 0 {
+		return errors.New("basket is empty")
+	}
+
+	// An empty currency matches any other, so items are compared with the first currency
+	// seen rather than with amount or the first item alone; Total panics on a mix.
+	currency := amount
+	var problems []string
+	for i, item := range b {
+		if strings.TrimSpace(item.Name) == "" {
+			problems = append(problems, fmt.Sprintf("item %d: name is empty", i))
+		}
+		if !item.UnitPrice.IsPositive() {
+			problems = append(problems, fmt.Sprintf("item %d: unit price must be positive", i))
+		}
+		if item.Quantity <= 0 {
+			problems = append(problems, fmt.Sprintf("item %d: quantity must be positive", i))
+		}
+		if !item.UnitPrice.SameCurrency(currency) {
+			problems = append(problems, fmt.Sprintf("item %d: currency %s does not match %s", i, item.UnitPrice.Currency, currency.Currency))
+		} else if currency.Currency == "" {
+			currency = item.UnitPrice
+		}
+		if item.VATRate < 0 || item.VATRate > 100 {
+			problems = append(problems, fmt.Sprintf("item %d: VAT rate must be between 0 and 100", i))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid basket: %s", strings.Join(problems, "; "))
+	}
+	if total := b.Total(); total.Cmp(amount) != 0 {
+		problems = append(problems, fmt.Sprintf("basket total %s does not match payment amount %s", total, amount))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid basket: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// JSON encodes the basket in PayTR's user_basket format, as sent by the Direct API:
+// [["Item name", "18.00", 1], ...]
+func (b Basket) JSON() string {
+	rows := make([][]interface{}, 0, len(b))
+	for _, item := range b {
+		rows = append(rows, []interface{}{item.Name, item.UnitPrice.String(), item.Quantity})
+	}
+	data, _ := json.Marshal(rows)
+	return string(data)
+}
+
+// Base64 encodes the basket as base64 encoded JSON, as sent by IFrameToken.
+// IFrameToken encodes the basket itself; it is not meant to be encoded again.
+func (b Basket) Base64() string {
+	return base64.StdEncoding.EncodeToString([]byte(b.JSON()))
+}
+
+// String returns the JSON encoding of the basket, which is the user_basket form value.
+func (b Basket) String() string {
+	return b.JSON()
+}
+
+// MarshalJSON encodes the basket as a JSON string holding Basket.JSON,
+// the user_basket value PayTR expects.
+func (b Basket) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.JSON())
+}
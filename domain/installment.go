@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InstallmentRates is the merchant's installment rate table, as returned by
+// PayTR's installment-rates endpoint.
+type InstallmentRates struct {
+	RequestID string `json:"request_id"`
+
+	// MaxInstallmentsNonBusiness is the highest installment count PayTR allows for
+	// cards that are not business cards (max_inst_non_bus). Zero means no limit was reported.
+	MaxInstallmentsNonBusiness int `json:"max_inst_non_bus"`
+
+	// Families maps a card family, e.g. bonus, world or axess, to its rates.
+	// The keys match BinDetails.CardFamily.
+	Families map[string]InstallmentRateTable `json:"families"`
+}
+
+// InstallmentRateTable maps an installment count to the rate added to the amount.
+type InstallmentRateTable map[int]InstallmentRate
+
+// InstallmentRate is a percentage in hundredths of a percent, e.g. 321 is 3.21%.
+// Rates are kept as integers so that the totals derived from them never drift.
+type InstallmentRate int64
+
+// ParseInstallmentRate parses a percentage as PayTR formats it, such as "3.21" or "3,21".
+// A percentage has no thousands separators, so any number of decimals is accepted;
+// rates more precise than a hundredth are rounded half away from zero, e.g. "3.215" is 3.22%.
+func ParseInstallmentRate(s string) (InstallmentRate, error) {
+	intPart, fracPart, hasFrac := strings.Cut(strings.Replace(strings.TrimSpace(s), ",", ".", 1), ".")
+	if !isDigits(intPart) || len(intPart) > 6 || (hasFrac && !isDigits(fracPart)) {
+		return 0, fmt.Errorf("invalid installment rate %q", s)
+	}
+
+	units, _ := strconv.ParseInt(intPart, 10, 64)
+	digits := fracPart + "00"
+	hundredths, _ := strconv.ParseInt(digits[:2], 10, 64)
+	rate := units*100 + hundredths
+	if len(fracPart) > 2 && fracPart[2] >= '5' {
+		rate++
+	}
+	return InstallmentRate(rate), nil
+}
+
+// String formats the rate with two decimals, e.g. "3.21".
+func (r InstallmentRate) String() string {
+	return Money{Minor: int64(r)}.String()
+}
+
+// Apply returns m increased by the rate, rounded half away from zero to the minor unit.
+func (r InstallmentRate) Apply(m Money) Money {
+	increase := m.Minor * int64(r)
+	rounded := increase / 10000
+	if rem := increase % 10000; rem >= 5000 {
+		rounded++
+	} else if rem <= -5000 {
+		rounded--
+	}
+	return Money{Minor: m.Minor + rounded, Currency: m.Currency}
+}
+
+// MarshalJSON encodes the rate as a decimal string, e.g. "3.21".
+func (r InstallmentRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts the rate either as a JSON string or a JSON number.
+func (r *InstallmentRate) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*r = 0
+		return nil
+	}
+	rate, err := ParseInstallmentRate(s)
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
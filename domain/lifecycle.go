@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PaymentStatus is a state in the lifecycle of a payment:
+//
+//	created → awaiting_3ds → succeeded → partially_refunded → refunded
+//	   │            │      ↘ failed
+//	   └────────────┴──────→ expired
+//
+// A payment may skip awaiting_3ds, and be refunded in full without being partially
+// refunded first. failed and refunded are final. An expired payment may still succeed
+// or fail if PayTR reports an outcome after it was given up on.
+type PaymentStatus string
+
+const (
+	PaymentStatusCreated           PaymentStatus = "created"
+	PaymentStatusAwaiting3DS       PaymentStatus = "awaiting_3ds"
+	PaymentStatusSucceeded         PaymentStatus = "succeeded"
+	PaymentStatusFailed            PaymentStatus = "failed"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+	PaymentStatusExpired           PaymentStatus = "expired"
+)
+
+// ErrIllegalTransition is returned when a payment is moved to a status that cannot
+// follow its current one, such as refunding a failed payment.
+var ErrIllegalTransition = errors.New("illegal payment status transition")
+
+var paymentTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentStatusCreated:           {PaymentStatusAwaiting3DS, PaymentStatusSucceeded, PaymentStatusFailed, PaymentStatusExpired},
+	PaymentStatusAwaiting3DS:       {PaymentStatusSucceeded, PaymentStatusFailed, PaymentStatusExpired},
+	PaymentStatusSucceeded:         {PaymentStatusPartiallyRefunded, PaymentStatusRefunded},
+	PaymentStatusPartiallyRefunded: {PaymentStatusPartiallyRefunded, PaymentStatusRefunded},
+	PaymentStatusExpired:           {PaymentStatusSucceeded, PaymentStatusFailed},
+}
+
+// CanTransition reports whether a payment in status s may move to status to.
+func (s PaymentStatus) CanTransition(to PaymentStatus) bool {
+	for _, next := range paymentTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no further transitions are possible from s.
+func (s PaymentStatus) IsFinal() bool {
+	return len(paymentTransitions[s]) == 0
+}
+
+// IsPaid reports whether the payment was charged, whether or not it has been refunded since.
+func (s PaymentStatus) IsPaid() bool {
+	return s == PaymentStatusSucceeded || s == PaymentStatusPartiallyRefunded || s == PaymentStatusRefunded
+}
+
+// StatusChange records a transition of a payment between two statuses.
+// Amount is set for refunds.
+type StatusChange struct {
+	From   PaymentStatus `bson:"from"`
+	To     PaymentStatus `bson:"to"`
+	At     time.Time     `bson:"at"`
+	Reason string        `bson:"reason,omitempty"`
+	Amount Money         `bson:"amount,omitempty"`
+}
+
+// NewPayment creates a payment in the created status.
+func NewPayment(merchantOid string, amount Money, at time.Time) *Payment {
+	return &Payment{
+		MerchantOid: merchantOid,
+		Amount:      amount,
+		Currency:    amount.Currency,
+		Status:      PaymentStatusCreated,
+		CreatedAt:   at,
+		UpdatedAt:   at,
+		History:     []StatusChange{{To: PaymentStatusCreated, At: at}},
+	}
+}
+
+// Transition moves the payment to status to and records the change in its history.
+// Moving a payment to the status it is already in is a no-op, except for recording
+// another partial refund. Any other move not allowed by the lifecycle returns an
+// error wrapping ErrIllegalTransition and leaves the payment unchanged.
+func (p *Payment) Transition(to PaymentStatus, at time.Time, reason string) error {
+	if p.Status == to && to != PaymentStatusPartiallyRefunded {
+		return nil
+	}
+	if !p.Status.CanTransition(to) {
+		return fmt.Errorf("%w: payment %s cannot move from %s to %s", ErrIllegalTransition, p.MerchantOid, p.Status, to)
+	}
+
+	p.History = append(p.History, StatusChange{From: p.Status, To: to, At: at, Reason: reason})
+	p.Status = to
+	p.UpdatedAt = at
+	return nil
+}
+
+// Refunds returns the status changes that recorded refunds, oldest first.
+func (p *Payment) Refunds() []StatusChange {
+	var refunds []StatusChange
+	for _, change := range p.History {
+		if change.To == PaymentStatusPartiallyRefunded || change.To == PaymentStatusRefunded {
+			refunds = append(refunds, change)
+		}
+	}
+	return refunds
+}
+
+// StatusAt returns the time the payment last entered status, and false if it never did.
+func (p *Payment) StatusAt(status PaymentStatus) (time.Time, bool) {
+	for i := len(p.History) - 1; i >= 0; i-- {
+		if p.History[i].To == status {
+			return p.History[i].At, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Refund records a successful refund of amount: the payment becomes refunded once
+// RefundedAmount reaches Amount, and partially refunded before that. Every refund
+// adds its own entry to History, even one made after the payment was refunded in full.
+// A refund in another currency than the payment's is rejected with ErrCurrencyMismatch.
+func (p *Payment) Refund(amount Money, at time.Time, reason string) error {
+	if !amount.SameCurrency(p.Amount) || !amount.SameCurrency(p.RefundedAmount) {
+		return fmt.Errorf("%w: refund in %s of payment %s in %s", ErrCurrencyMismatch, amount.Currency, p.MerchantOid, p.Amount.Currency)
+	}
+	refunded := p.RefundedAmount.Add(amount)
+	refunded.Currency = p.Amount.Currency
+
+	to := PaymentStatusPartiallyRefunded
+	if refunded.Cmp(p.Amount) >= 0 {
+		to = PaymentStatusRefunded
+	}
+	changes := len(p.History)
+	if err := p.Transition(to, at, reason); err != nil {
+		return err
+	}
+	if len(p.History) == changes {
+		p.History = append(p.History, StatusChange{From: p.Status, To: to, At: at, Reason: reason})
+		p.UpdatedAt = at
+	}
+	p.History[len(p.History)-1].Amount = amount
+	p.RefundedAmount = refunded
+	return nil
+}
@@ -0,0 +1,215 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrCurrencyMismatch is returned when amounts in different currencies would be combined.
+// Add, Sub and Cmp panic instead; check SameCurrency before calling them on caller input.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Money is an exact monetary amount stored in integer minor units (kuruş, cents)
+// together with its currency code. It is used for every amount that ends up in a
+// request or a token so that rounding can never change a signature or a charge.
+type Money struct {
+	Minor    int64  `json:"minor" bson:"minor"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+// NewMoney returns the amount of minor units in the given currency,
+// e.g. NewMoney(10050, "TRY") is 100.50 TRY.
+func NewMoney(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// ParseMoney parses an amount as PayTR formats it, such as "100", "100.5", "100.50",
+// "100,50", "1.234,56" or "1,234.56". The last separator is the decimal one unless it
+// is repeated. A single separator followed by exactly three digits, such as "1.000",
+// is rejected as it may group thousands or mark decimals. Amounts with more than two
+// significant decimals are rejected instead of being rounded.
+func ParseMoney(s, currency string) (Money, error) {
+	minor, err := parseMinor(s)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// String formats the amount with two decimals and a dot separator, which is the
+// format PayTR expects in requests and tokens (e.g. "100.50").
+func (m Money) String() string {
+	sign := ""
+	minor := m.Minor
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/100, minor%100)
+}
+
+// MinorString formats the amount in minor units (e.g. "10050"), as used by the iFrame API.
+func (m Money) MinorString() string {
+	return strconv.FormatInt(m.Minor, 10)
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// IsPositive reports whether the amount is greater than zero.
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+// Add returns m + o. It panics if the currencies differ; see SameCurrency.
+func (m Money) Add(o Money) Money {
+	return Money{Minor: m.Minor + o.Minor, Currency: m.mustMatch(o)}
+}
+
+// Sub returns m - o. It panics if the currencies differ; see SameCurrency.
+func (m Money) Sub(o Money) Money {
+	return Money{Minor: m.Minor - o.Minor, Currency: m.mustMatch(o)}
+}
+
+// Mul returns m multiplied by n.
+func (m Money) Mul(n int64) Money {
+	return Money{Minor: m.Minor * n, Currency: m.Currency}
+}
+
+// Cmp compares the amounts of m and o and returns -1, 0 or +1.
+// It panics if the currencies differ; see SameCurrency.
+func (m Money) Cmp(o Money) int {
+	m.mustMatch(o)
+	switch {
+	case m.Minor < o.Minor:
+		return -1
+	case m.Minor > o.Minor:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SameCurrency reports whether m and o can be added or compared: their currencies are
+// equal, one of them is unset, or they are TL and TRY, which PayTR uses interchangeably.
+func (m Money) SameCurrency(o Money) bool {
+	return m.Currency == "" || o.Currency == "" || normalizeCurrency(m.Currency) == normalizeCurrency(o.Currency)
+}
+
+// mustMatch panics if the currencies of m and o differ and returns the currency of the result.
+func (m Money) mustMatch(o Money) string {
+	if !m.SameCurrency(o) {
+		panic(fmt.Sprintf("domain: currency mismatch: %s and %s", m.Currency, o.Currency))
+	}
+	if m.Currency == "" {
+		return o.Currency
+	}
+	return m.Currency
+}
+
+func normalizeCurrency(currency string) string {
+	currency = strings.ToUpper(currency)
+	if currency == "TL" {
+		return "TRY"
+	}
+	return currency
+}
+
+// MarshalJSON encodes the amount as a decimal string, e.g. "100.50".
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts the amount either as a JSON string or a JSON number.
+// The currency is left unchanged.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		m.Minor = 0
+		return nil
+	}
+	minor, err := parseMinor(s)
+	if err != nil {
+		return err
+	}
+	m.Minor = minor
+	return nil
+}
+
+// parseMinor converts a decimal amount string into minor units.
+func parseMinor(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	negative := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(str, "-")
+	if str == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	intPart, fracPart := str, ""
+	lastDot, lastComma := strings.LastIndex(str, "."), strings.LastIndex(str, ",")
+	sep := lastDot
+	if lastComma > sep {
+		sep = lastComma
+	}
+	if sep >= 0 {
+		// A separator repeated without the other kind ("1.234.567") only groups thousands.
+		single := lastDot < 0 || lastComma < 0
+		repeated := strings.Count(str, string(str[sep])) > 1 && single
+		if !repeated {
+			intPart, fracPart = str[:sep], str[sep+1:]
+			if single && len(fracPart) == 3 {
+				return 0, fmt.Errorf("invalid amount %q: ambiguous separator", s)
+			}
+		}
+	}
+	intPart = strings.NewReplacer(".", "", ",", "").Replace(intPart)
+
+	if intPart == "" {
+		intPart = "0"
+	}
+	if !isDigits(intPart) || (fracPart != "" && !isDigits(fracPart)) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(fracPart) > 2 {
+		if strings.Trim(fracPart[2:], "0") != "" {
+			return 0, fmt.Errorf("invalid amount %q: more than two decimals", s)
+		}
+		fracPart = fracPart[:2]
+	}
+	for len(fracPart) < 2 {
+		fracPart += "0"
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	cents, err := strconv.ParseInt(fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if units > (math.MaxInt64-cents)/100 {
+		return 0, fmt.Errorf("invalid amount %q: out of range", s)
+	}
+
+	minor := units*100 + cents
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -5,29 +5,48 @@ import (
 )
 
 const (
-	PayTRBaseURL = "https://www.paytr.com"
+	PayTRBaseURL   = "https://www.paytr.com"
+	PayTRIFrameURL = PayTRBaseURL + string(EndpointIFrame)
+)
+
+// Endpoint is the path of a PayTR API endpoint relative to the base URL.
+type Endpoint string
+
+const (
+	EndpointPayment            Endpoint = "/odeme"
+	EndpointRefund             Endpoint = "/odeme/iade"
+	EndpointStatusInquiry      Endpoint = "/odeme/durum-sorgu"
+	EndpointTransactionDetails Endpoint = "/rapor/islem-dokumu"
+	EndpointBinDetail          Endpoint = "/odeme/api/bin-detail"
+	EndpointIFrameToken        Endpoint = "/odeme/api/get-token"
+	EndpointIFrame             Endpoint = "/odeme/guvenli/"
+	EndpointCardList           Endpoint = "/odeme/capi/list"
+	EndpointCardDelete         Endpoint = "/odeme/capi/delete"
+	EndpointInstallmentRates   Endpoint = "/odeme/taksit-oranlari"
+	EndpointPlatformTransfer   Endpoint = "/odeme/platform/transfer"
+	EndpointReturnedTransfers  Endpoint = "/odeme/geri-donen-transfer"
 )
 
 type CommonPaymentRequest struct {
-	MerchantID       string  `json:"merchant_id"`
-	UserIP           string  `json:"user_ip"`
-	MerchantOid      string  `json:"merchant_oid"`
-	Email            string  `json:"email"`
-	PaymentAmount    float64 `json:"payment_amount"`
-	PaymentType      string  `json:"payment_type"`
-	Currency         string  `json:"currency"`
-	TestMode         string  `json:"test_mode"`
-	NonThreeD        string  `json:"non_3d"`
-	MerchantOkURL    string  `json:"merchant_ok_url"`
-	MerchantFailURL  string  `json:"merchant_fail_url"`
-	UserName         string  `json:"user_name"`
-	UserAddress      string  `json:"user_address"`
-	UserPhone        string  `json:"user_phone"`
-	UserBasket       string  `json:"user_basket"`
-	DebugOn          string  `json:"debug_on"`
-	ClientLang       string  `json:"client_lang"`
-	PayTRToken       string  `json:"paytr_token"`
-	InstallmentCount string  `json:"installment_count"`
+	MerchantID       string `json:"merchant_id"` // Set by the service from its configuration.
+	UserIP           string `json:"user_ip"`
+	MerchantOid      string `json:"merchant_oid"`
+	Email            string `json:"email"`
+	PaymentAmount    Money  `json:"payment_amount"`
+	PaymentType      string `json:"payment_type"`
+	Currency         string `json:"currency"`
+	TestMode         string `json:"test_mode"`
+	NonThreeD        string `json:"non_3d"`
+	MerchantOkURL    string `json:"merchant_ok_url"`
+	MerchantFailURL  string `json:"merchant_fail_url"`
+	UserName         string `json:"user_name"`
+	UserAddress      string `json:"user_address"`
+	UserPhone        string `json:"user_phone"`
+	UserBasket       Basket `json:"user_basket"` // Sent as JSON, see Basket.JSON.
+	DebugOn          string `json:"debug_on"`
+	ClientLang       string `json:"client_lang"`
+	PayTRToken       string `json:"paytr_token"`
+	InstallmentCount string `json:"installment_count"`
 }
 
 type NewCardPaymentRequest struct {
@@ -50,19 +69,36 @@ type NewCardPaymentRequest struct {
 type PayTRResponse struct {
 	Status  string                 `json:"status"`
 	Message string                 `json:"message"`
+	ErrNo   string                 `json:"err_no,omitempty"`
+	ErrMsg  string                 `json:"err_msg,omitempty"`
+	Reason  string                 `json:"reason,omitempty"`
 	Data    map[string]interface{} `json:"data,omitempty"`
 }
 
+// Payment methods recorded by the service.
+const (
+	PaymentMethodCard           = "card"
+	PaymentMethodSavedCard      = "saved_card"
+	PaymentMethodRecurring      = "recurring"
+	PaymentMethodCardValidation = "card_validation"
+	PaymentMethodIFrame         = "iframe"
+)
+
+// Payment is the local record of a payment. Its Status follows the lifecycle described
+// by PaymentStatus and is changed with Transition, which records every change in History.
 type Payment struct {
-	ID            string    `bson:"_id,omitempty"`
-	UserID        string    `bson:"user_id"`
-	Amount        float64   `bson:"amount"`
-	Currency      string    `bson:"currency"`
-	Status        string    `bson:"status"`
-	PaymentMethod string    `bson:"payment_method"`
-	MerchantOid   string    `bson:"merchant_oid"`
-	CreatedAt     time.Time `bson:"created_at"`
-	UpdatedAt     time.Time `bson:"updated_at"`
+	ID             string         `bson:"_id,omitempty"`
+	UserID         string         `bson:"user_id"`
+	Amount         Money          `bson:"amount"`
+	RefundedAmount Money          `bson:"refunded_amount"`
+	Currency       string         `bson:"currency"`
+	Status         PaymentStatus  `bson:"status"`
+	PaymentMethod  string         `bson:"payment_method"`
+	MerchantOid    string         `bson:"merchant_oid"`
+	History        []StatusChange `bson:"history"`
+	CreatedAt      time.Time      `bson:"created_at"`
+	UpdatedAt      time.Time      `bson:"updated_at"`
+	Version        int            `bson:"version"` // Incremented by every save, see PaymentRepository.SavePayment.
 }
 
 type SavedCard struct {
@@ -71,8 +107,15 @@ type SavedCard struct {
 	UToken     string    `bson:"utoken"`
 	CToken     string    `bson:"ctoken"`
 	LastFour   string    `bson:"last_four"`
-	CardType   string    `bson:"card_type"`
-	ExpiryDate string    `bson:"expiry_date"`
+	CardType   string    `bson:"card_type"`   // credit or debit.
+	ExpiryDate string    `bson:"expiry_date"` // MM/YY
+	Month      string    `bson:"month"`
+	Year       string    `bson:"year"`
+	Bank       string    `bson:"bank"`
+	OwnerName  string    `bson:"owner_name"`
+	Brand      string    `bson:"brand"`  // Card family, e.g. bonus, world, axess.
+	Schema     string    `bson:"schema"` // Card scheme, e.g. VISA, MASTERCARD, TROY.
+	RequireCVV bool      `bson:"require_cvv"`
 	CreatedAt  time.Time `bson:"created_at"`
 }
 
@@ -85,30 +128,30 @@ type SavedCardPaymentRequest struct {
 }
 
 type AddNewCardRequest struct {
-	UserID          string  `json:"user_id"`
-	CardOwner       string  `json:"cc_owner"`
-	CardNumber      string  `json:"card_number"`
-	ExpiryMonth     string  `json:"expiry_month"`
-	ExpiryYear      string  `json:"expiry_year"`
-	CVV             string  `json:"cvv"`
-	CardType        string  `json:"card_type"`
-	UserIP          string  `json:"user_ip"`
-	MerchantOid     string  `json:"merchant_oid"`
-	Email           string  `json:"email"`
-	UserName        string  `json:"user_name"`
-	UserAddress     string  `json:"user_address"`
-	UserPhone       string  `json:"user_phone"`
-	MerchantOkURL   string  `json:"merchant_ok_url"`
-	MerchantFailURL string  `json:"merchant_fail_url"`
-	ClientLang      string  `json:"client_lang"`
-	Currency        string  `json:"currency"`
-	PaymentAmount   float64 `json:"payment_amount"`
+	UserID          string `json:"user_id"`
+	CardOwner       string `json:"cc_owner"`
+	CardNumber      string `json:"card_number"`
+	ExpiryMonth     string `json:"expiry_month"`
+	ExpiryYear      string `json:"expiry_year"`
+	CVV             string `json:"cvv"`
+	CardType        string `json:"card_type"`
+	UserIP          string `json:"user_ip"`
+	MerchantOid     string `json:"merchant_oid"`
+	Email           string `json:"email"`
+	UserName        string `json:"user_name"`
+	UserAddress     string `json:"user_address"`
+	UserPhone       string `json:"user_phone"`
+	MerchantOkURL   string `json:"merchant_ok_url"`
+	MerchantFailURL string `json:"merchant_fail_url"`
+	ClientLang      string `json:"client_lang"`
+	Currency        string `json:"currency"`
+	PaymentAmount   Money  `json:"payment_amount"`
 }
 
 type RefundRequest struct {
-	MerchantOid  string  `json:"merchant_oid"`
-	ReturnAmount float64 `json:"return_amount"`
-	ReferenceNo  string  `json:"reference_no,omitempty"`
+	MerchantOid  string `json:"merchant_oid"`
+	ReturnAmount Money  `json:"return_amount"`
+	ReferenceNo  string `json:"reference_no,omitempty"`
 }
 
 type StatusInquiryRequest struct {
@@ -134,6 +177,16 @@ type StatusInquiryResponse struct {
 	SubmerchantPayments []SubmerchantPayment `json:"submerchant_payments,omitempty"`
 }
 
+// Amount parses PaymentAmount, the amount of the order, in the response's currency.
+func (r StatusInquiryResponse) Amount() (Money, error) {
+	return ParseMoney(r.PaymentAmount, r.Currency)
+}
+
+// Total parses PaymentTotal, the amount paid by the customer including installment fees.
+func (r StatusInquiryResponse) Total() (Money, error) {
+	return ParseMoney(r.PaymentTotal, r.Currency)
+}
+
 type SubmerchantPayment struct {
 	SubmerchantId           string `json:"submerchant_id"`
 	SubmerchantPrice        string `json:"submerchant_price"`
@@ -168,3 +221,75 @@ type Transaction struct {
 	SiparisNo     string `json:"siparis_no"`
 	OdemeTipi     string `json:"odeme_tipi"`
 }
+
+// Amount parses IslemTutari, the transaction amount, in the transaction's currency.
+func (t Transaction) Amount() (Money, error) {
+	return ParseMoney(t.IslemTutari, t.ParaBirimi)
+}
+
+// PaymentNotification is the server-to-server notification PayTR posts to the
+// merchant's callback URL once a payment has completed or failed.
+type PaymentNotification struct {
+	MerchantOid      string `json:"merchant_oid"`
+	Status           string `json:"status"`
+	TotalAmount      string `json:"total_amount"`
+	Hash             string `json:"hash"`
+	FailedReasonCode string `json:"failed_reason_code,omitempty"`
+	FailedReasonMsg  string `json:"failed_reason_msg,omitempty"`
+	TestMode         string `json:"test_mode,omitempty"`
+	PaymentType      string `json:"payment_type,omitempty"`
+	Currency         string `json:"currency,omitempty"`
+	PaymentAmount    string `json:"payment_amount,omitempty"`
+	InstallmentCount string `json:"installment_count,omitempty"`
+}
+
+// IFrameTokenRequest holds the fields of an iFrame API get-token request.
+// UserBasket is sent base64 encoded, see Basket.Base64.
+type IFrameTokenRequest struct {
+	MerchantID      string `json:"merchant_id"`
+	UserIP          string `json:"user_ip"`
+	MerchantOid     string `json:"merchant_oid"`
+	Email           string `json:"email"`
+	PaymentAmount   Money  `json:"payment_amount"`
+	Currency        string `json:"currency"`
+	UserBasket      Basket `json:"user_basket"`
+	NoInstallment   string `json:"no_installment"`
+	MaxInstallment  string `json:"max_installment"`
+	TestMode        string `json:"test_mode"`
+	DebugOn         string `json:"debug_on"`
+	TimeoutLimit    string `json:"timeout_limit,omitempty"`
+	Lang            string `json:"lang,omitempty"`
+	UserName        string `json:"user_name"`
+	UserAddress     string `json:"user_address"`
+	UserPhone       string `json:"user_phone"`
+	MerchantOkURL   string `json:"merchant_ok_url"`
+	MerchantFailURL string `json:"merchant_fail_url"`
+	PayTRToken      string `json:"paytr_token"`
+}
+
+// IFrameTokenResponse is the result of an iFrame API get-token request.
+// IFrameURL is the address to load in the checkout iframe.
+type IFrameTokenResponse struct {
+	Status    string `json:"status"`
+	Token     string `json:"token,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	IFrameURL string `json:"-"`
+}
+
+// BinDetails describes the card range identified by a BIN (Bank Identification Number).
+type BinDetails struct {
+	BIN                string `json:"bin_number"`
+	Bank               string `json:"bank"`
+	BankCode           string `json:"bank_code,omitempty"`
+	Brand              string `json:"brand"`       // Card scheme, e.g. VISA, MASTERCARD, TROY, AMEX.
+	CardType           string `json:"card_type"`   // credit or debit.
+	CardFamily         string `json:"card_family"` // Installment program, e.g. bonus, world, axess.
+	BusinessCard       bool   `json:"business_card"`
+	AllowNon3D         bool   `json:"allow_non3d"`
+	InstallmentAllowed bool   `json:"installment_allowed"`
+}
+
+// IsCredit reports whether the card is a credit card.
+func (b BinDetails) IsCredit() bool {
+	return b.CardType == "credit"
+}
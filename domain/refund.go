@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RefundRecord is a refund of a payment, as listed in the returns of a status inquiry.
+type RefundRecord struct {
+	Amount      Money     // return_amount
+	ReferenceNo string    // reference_no, empty if the refund was sent without one
+	Type        string    // return_type
+	Status      string    // return_status, e.g. success
+	Source      string    // return_source, e.g. api or panel
+	RequestedAt time.Time // return_date, zero if not reported
+	CompletedAt time.Time // date_completed, zero until the refund is completed
+}
+
+// Failed reports whether PayTR reported the refund as failed. Failed refunds
+// do not reduce the refundable amount.
+func (r RefundRecord) Failed() bool {
+	switch strings.ToLower(r.Status) {
+	case "failed", "error", "cancelled", "canceled":
+		return true
+	}
+	return false
+}
+
+// RefundSummary sums up the refunds of a payment.
+type RefundSummary struct {
+	MerchantOid string
+	Paid        Money // payment_amount of the order
+	Refunded    Money // sum of the refunds that did not fail
+	Remaining   Money // amount that can still be refunded
+	Refunds     []RefundRecord
+}
+
+// ParseReturns parses the returns field of a status inquiry, a JSON list of refunds such as
+// [{"return_amount": "40.00", "return_date": "2024-05-01 14:30:00", "reference_no": "R1", ...}].
+// A JSON object holding the refunds by index is accepted as well. Amounts are read in currency.
+func ParseReturns(returns, currency string) ([]RefundRecord, error) {
+	returns = strings.TrimSpace(returns)
+	if returns == "" || returns == "null" || returns == "[]" || returns == "{}" {
+		return nil, nil
+	}
+
+	var items []map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(returns))
+	decoder.UseNumber()
+	if err := decoder.Decode(&items); err != nil {
+		var byIndex map[string]map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(returns))
+		decoder.UseNumber()
+		if decoder.Decode(&byIndex) != nil {
+			return nil, fmt.Errorf("returns: invalid list of refunds %q", returns)
+		}
+		keys := make([]string, 0, len(byIndex))
+		for key := range byIndex {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys {
+			items = append(items, byIndex[key])
+		}
+	}
+
+	records := make([]RefundRecord, len(items))
+	for i, item := range items {
+		field := func(name string) string {
+			if v, ok := item[name]; ok && v != nil {
+				return strings.TrimSpace(fmt.Sprint(v))
+			}
+			return ""
+		}
+
+		p := &fieldParser{}
+		records[i] = RefundRecord{
+			Amount:      p.money("return_amount", field("return_amount"), currency),
+			ReferenceNo: field("reference_no"),
+			Type:        field("return_type"),
+			Status:      field("return_status"),
+			Source:      field("return_source"),
+		}
+		if date := field("return_date"); date != "" {
+			records[i].RequestedAt = p.time("return_date", date)
+		}
+		if date := field("date_completed"); date != "" {
+			records[i].CompletedAt = p.time("date_completed", date)
+		}
+		if p.err != nil {
+			return nil, fmt.Errorf("returns: refund %d: %v", i+1, p.err)
+		}
+	}
+	return records, nil
+}
+
+// Refunds parses the returns of the response with ParseReturns.
+func (r StatusInquiryResponse) Refunds() ([]RefundRecord, error) {
+	return ParseReturns(r.Returns, r.Currency)
+}
+
+// RefundSummary computes the refunded and remaining refundable amounts of the payment
+// the response describes. Nothing is refundable unless the payment succeeded.
+func (r StatusInquiryResponse) RefundSummary(merchantOid string) (RefundSummary, error) {
+	refunds, err := r.Refunds()
+	if err != nil {
+		return RefundSummary{}, err
+	}
+	p := &fieldParser{}
+	paid := p.money("payment_amount", r.PaymentAmount, r.Currency)
+	if p.err != nil {
+		return RefundSummary{}, p.err
+	}
+
+	summary := RefundSummary{
+		MerchantOid: merchantOid,
+		Paid:        paid,
+		Refunded:    NewMoney(0, r.Currency),
+		Refunds:     refunds,
+	}
+	for _, refund := range refunds {
+		if !refund.Failed() {
+			summary.Refunded = summary.Refunded.Add(refund.Amount)
+		}
+	}
+	summary.Remaining = paid.Sub(summary.Refunded)
+	if r.Status != "success" || summary.Remaining.Minor < 0 {
+		summary.Remaining = NewMoney(0, r.Currency)
+	}
+	return summary, nil
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by repositories when the requested record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned by SavePayment when the stored payment changed since it was
+// read, or when a new payment is saved under a MerchantOid that is already taken.
+var ErrConflict = errors.New("record was modified concurrently")
+
+// PaymentRepository stores payments keyed by MerchantOid.
+type PaymentRepository interface {
+	// SavePayment inserts the payment or replaces the one with the same MerchantOid.
+	// Saves are compare-and-swap on Version: a payment with Version zero is inserted,
+	// any other replaces the stored payment only if that still has the same Version.
+	// Otherwise ErrConflict is returned. On success Version is incremented.
+	SavePayment(ctx context.Context, payment *Payment) error
+
+	// FindPayment returns the payment with the given MerchantOid, or ErrNotFound.
+	FindPayment(ctx context.Context, merchantOid string) (*Payment, error)
+
+	// ListPayments returns the payments of a user, oldest first.
+	ListPayments(ctx context.Context, userID string) ([]Payment, error)
+
+	// ListPaymentsUpdatedSince returns the payments whose UpdatedAt is not before since,
+	// least recently updated first.
+	ListPaymentsUpdatedSince(ctx context.Context, since time.Time) ([]Payment, error)
+}
+
+// CardRepository stores saved cards keyed by UToken and CToken.
+type CardRepository interface {
+	// SaveCard inserts the card or replaces the one with the same UToken and CToken.
+	SaveCard(ctx context.Context, card *SavedCard) error
+
+	// ListCards returns the cards saved under a user token, oldest first.
+	ListCards(ctx context.Context, utoken string) ([]SavedCard, error)
+
+	// DeleteCard removes a saved card, or returns ErrNotFound.
+	DeleteCard(ctx context.Context, utoken, ctoken string) error
+}
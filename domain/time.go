@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// ReportTimeFormat is the layout of the dates PayTR reports and accepts, e.g. "2024-05-01 14:30:00".
+const ReportTimeFormat = "2006-01-02 15:04:05"
+
+// PayTRLocation is the time zone of PayTR's dates, Europe/Istanbul.
+var PayTRLocation = loadPayTRLocation()
+
+func loadPayTRLocation() *time.Location {
+	if loc, err := time.LoadLocation("Europe/Istanbul"); err == nil {
+		return loc
+	}
+	// Without a time zone database, fall back to Turkey's offset, which has been UTC+3 all year since 2016.
+	return time.FixedZone("+03", 3*60*60)
+}
@@ -0,0 +1,211 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TransactionKind is the kind of a reported transaction.
+type TransactionKind string
+
+const (
+	TransactionKindSale   TransactionKind = "sale"
+	TransactionKindRefund TransactionKind = "refund"
+	TransactionKindOther  TransactionKind = "other"
+)
+
+// Kind maps IslemTipi, the transaction type, to a TransactionKind.
+func (t Transaction) Kind() TransactionKind {
+	switch strings.ToLower(strings.TrimSpace(t.IslemTipi)) {
+	case "satis", "satış", "s", "sale":
+		return TransactionKindSale
+	case "iade", "i", "refund":
+		return TransactionKindRefund
+	}
+	return TransactionKindOther
+}
+
+// TransactionView is a normalized, English view of a reported Transaction.
+type TransactionView struct {
+	Kind         TransactionKind
+	MerchantOid  string    // siparis_no
+	Amount       Money     // islem_tutari, the amount of the transaction
+	PaidAmount   Money     // odeme_tutari, the amount paid by the customer including installment fees
+	NetAmount    Money     // net_tutar, the amount credited to the merchant
+	FeeAmount    Money     // kesinti_tutari, PayTR's commission
+	FeeRate      float64   // kesinti_orani, as a percentage, e.g. 1.99
+	OccurredAt   time.Time // islem_tarihi
+	Currency     string    // para_birimi
+	Installments int       // taksit, 1 for a single payment
+	CardBrand    string    // kart_marka
+	MaskedPan    string    // kart_no
+	PaymentType  string    // odeme_tipi
+
+	// Raw is the transaction as reported.
+	Raw Transaction
+}
+
+// View parses the transaction into a TransactionView. Empty amounts are read as zero.
+func (t Transaction) View() (TransactionView, error) {
+	p := &fieldParser{}
+	v := TransactionView{
+		Kind:         t.Kind(),
+		MerchantOid:  t.SiparisNo,
+		Amount:       p.money("islem_tutari", t.IslemTutari, t.ParaBirimi),
+		PaidAmount:   p.money("odeme_tutari", t.OdemeTutari, t.ParaBirimi),
+		NetAmount:    p.money("net_tutar", t.NetTutar, t.ParaBirimi),
+		FeeAmount:    p.money("kesinti_tutari", t.KesintiTutari, t.ParaBirimi),
+		FeeRate:      p.rate("kesinti_orani", t.KesintiOrani),
+		OccurredAt:   p.time("islem_tarihi", t.IslemTarihi),
+		Currency:     t.ParaBirimi,
+		Installments: p.installments("taksit", t.Taksit),
+		CardBrand:    t.KartMarka,
+		MaskedPan:    t.KartNo,
+		PaymentType:  t.OdemeTipi,
+		Raw:          t,
+	}
+	return v, p.err
+}
+
+// StatusView is a normalized, English view of a StatusInquiryResponse.
+type StatusView struct {
+	Status       string
+	Amount       Money     // payment_amount, the amount of the order
+	Total        Money     // payment_total, the amount paid by the customer including installment fees
+	NetAmount    Money     // net_tutar, the amount credited to the merchant
+	FeeAmount    Money     // kesinti_tutari, PayTR's commission
+	PaidAt       time.Time // payment_date, zero if not reported
+	Currency     string
+	Installments int    // taksit, 1 for a single payment
+	CardBrand    string // kart_marka
+	MaskedPan    string
+	PaymentType  string // odeme_tipi
+	TestMode     bool
+
+	// Raw is the response as received.
+	Raw StatusInquiryResponse
+}
+
+// View parses the response into a StatusView. Empty amounts and dates are read as zero.
+func (r StatusInquiryResponse) View() (StatusView, error) {
+	p := &fieldParser{}
+	v := StatusView{
+		Status:       r.Status,
+		Amount:       p.money("payment_amount", r.PaymentAmount, r.Currency),
+		Total:        p.money("payment_total", r.PaymentTotal, r.Currency),
+		NetAmount:    p.money("net_tutar", r.NetTutar, r.Currency),
+		FeeAmount:    p.money("kesinti_tutari", r.KesintiTutari, r.Currency),
+		Currency:     r.Currency,
+		Installments: p.installments("taksit", r.Taksit),
+		CardBrand:    r.KartMarka,
+		MaskedPan:    r.MaskedPan,
+		PaymentType:  r.OdemeTipi,
+		TestMode:     r.TestMode == "1",
+		Raw:          r,
+	}
+	if strings.TrimSpace(r.PaymentDate) != "" {
+		v.PaidAt = p.time("payment_date", r.PaymentDate)
+	}
+	return v, p.err
+}
+
+// reportTimeLayouts are the date formats found in PayTR's responses, all in PayTRLocation.
+var reportTimeLayouts = []string{
+	ReportTimeFormat,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02/01/2006 15:04:05",
+	"2006-01-02",
+	"02.01.2006",
+}
+
+// ParseReportTime parses a date as PayTR reports it, e.g. "2024-05-01 14:30:00" or "01.05.2024 14:30".
+// Dates without a zone are read in PayTRLocation.
+func ParseReportTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range reportTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, PayTRLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// fieldParser parses the fields of a response and keeps the first error.
+type fieldParser struct {
+	err error
+}
+
+func (p *fieldParser) fail(field string, err error) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%s: %v", field, err)
+	}
+}
+
+// money parses an amount, ignoring currency names and symbols such as "100,50 TL" or "₺100,50".
+func (p *fieldParser) money(field, value, currency string) Money {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) || r == '₺' || r == '$' || r == '€' || r == '£' {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return NewMoney(0, currency)
+	}
+	m, err := ParseMoney(value, currency)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return m
+}
+
+// rate parses a percentage such as "1.99", "1,99" or "%1,99".
+func (p *fieldParser) rate(field, value string) float64 {
+	value = strings.TrimSpace(strings.Trim(strings.TrimSpace(value), "%"))
+	if value == "" {
+		return 0
+	}
+	r, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		p.fail(field, fmt.Errorf("invalid rate %q", value))
+	}
+	return r
+}
+
+func (p *fieldParser) time(field, value string) time.Time {
+	t, err := ParseReportTime(value)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return t
+}
+
+// installments parses an installment count. PayTR reports a single payment as "0",
+// "1", an empty value or a name such as "Tek Çekim"; all of them are read as 1.
+func (p *fieldParser) installments(field, value string) int {
+	digits := strings.TrimFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i := strings.IndexFunc(digits, func(r rune) bool { return !unicode.IsDigit(r) }); i >= 0 {
+		digits = digits[:i]
+	}
+	if digits == "" {
+		return 1
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		p.fail(field, fmt.Errorf("invalid installment count %q", value))
+		return 1
+	}
+	if n < 1 {
+		return 1
+	}
+	return n
+}
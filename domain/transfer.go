@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// PlatformTransferRequest sends a submerchant's share of a marketplace payment to the
+// submerchant's IBAN. The amounts are sent to PayTR in minor units.
+type PlatformTransferRequest struct {
+	MerchantOid       string `json:"merchant_oid"`
+	TransID           string `json:"trans_id"` // Unique ID of the transfer, chosen by the merchant.
+	SubmerchantAmount Money  `json:"submerchant_amount"`
+	TotalAmount       Money  `json:"total_amount"` // Amount of the payment the share is taken from.
+	TransferName      string `json:"transfer_name"`
+	TransferIBAN      string `json:"transfer_iban"`
+}
+
+// TransferNotification is the notification PayTR posts to the merchant's platform
+// transfer result URL once transfers have been completed.
+type TransferNotification struct {
+	TransIDs []string `json:"trans_ids"`
+
+	// RawTransIDs is the JSON encoded trans_ids field as posted, which the hash is computed over.
+	RawTransIDs string `json:"-"`
+	Hash        string `json:"hash"`
+}
+
+// ReturnedTransfersRequest selects the transfers returned by the receiving bank in the
+// period [StartDate, EndDate). The dates are sent in PayTR's time zone with a precision
+// of one second, like those of the transaction report.
+type ReturnedTransfersRequest struct {
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+}
+
+// ReturnedTransfer is a transfer the receiving bank sent back, e.g. because the IBAN
+// was closed or did not match the transfer name.
+type ReturnedTransfer struct {
+	RefNo          string `json:"ref_no"`
+	DateDetected   string `json:"date_detected"`
+	DateReimbursed string `json:"date_reimbursed"`
+	TransferName   string `json:"transfer_name"`
+	TransferIBAN   string `json:"transfer_iban"`
+	TransferAmount Money  `json:"transfer_amount"`
+	TransferDate   string `json:"transfer_date"`
+}
@@ -1,5 +1,5 @@
 module github.com/streamerd/paytr-go
 
-go 1.22.4
+go 1.23
 
-require github.com/mitchellh/mapstructure v1.5.0 // indirect
+require github.com/mitchellh/mapstructure v1.5.0
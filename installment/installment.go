@@ -0,0 +1,92 @@
+// Package installment computes the installment options of an amount offline, from the
+// rate table returned by payment.Service.GetInstallmentRates and the card family returned
+// by GetBinDetails.
+//
+// The rate table changes rarely, so it can be fetched once and cached, and product pages
+// can show offers such as "12 x 99.90 TL" without a request per page view.
+//
+// Example usage:
+//
+//	rates, err := svc.GetInstallmentRates()
+//	...
+//	card, err := svc.GetBinDetails(bin)
+//	...
+//	for _, o := range installment.Options(domain.NewMoney(119880, "TL"), *card, *rates) {
+//		fmt.Printf("%d x %s TL (total %s TL)\n", o.Count, o.Monthly, o.Total)
+//	}
+package installment
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// Option is one way of paying an amount.
+type Option struct {
+	Count int                    // Number of installments; 1 is a single payment.
+	Rate  domain.InstallmentRate // Rate added to the amount.
+	Total domain.Money           // Amount charged in total, including the rate.
+
+	// Monthly is Total divided by Count, rounded half up to the minor unit.
+	// Monthly × Count may therefore differ from Total by less than Count minor units.
+	Monthly domain.Money
+}
+
+// Options returns the installment options of amount for a card, as described by
+// GetBinDetails, ordered by installment count. The first option is always the single
+// payment, which carries no rate. Cards for which PayTR does not allow installments and
+// families without rates, such as the "none" family of debit cards, only have the single
+// payment. Cards that are not business cards get no more installments than
+// rates.MaxInstallmentsNonBusiness.
+func Options(amount domain.Money, card domain.BinDetails, rates domain.InstallmentRates) []Option {
+	options := []Option{{Count: 1, Total: amount, Monthly: amount}}
+	if !card.InstallmentAllowed {
+		return options
+	}
+
+	limit := 0
+	if !card.BusinessCard {
+		limit = rates.MaxInstallmentsNonBusiness
+	}
+
+	table := rates.Families[strings.ToLower(card.CardFamily)]
+	counts := make([]int, 0, len(table))
+	for count := range table {
+		if count > 1 && (limit == 0 || count <= limit) {
+			counts = append(counts, count)
+		}
+	}
+	sort.Ints(counts)
+
+	for _, count := range counts {
+		options = append(options, Calculate(amount, count, table[count]))
+	}
+	return options
+}
+
+// Calculate returns the option of paying amount in count installments at rate.
+func Calculate(amount domain.Money, count int, rate domain.InstallmentRate) Option {
+	if count < 1 {
+		count = 1
+	}
+	total := rate.Apply(amount)
+	return Option{
+		Count:   count,
+		Rate:    rate,
+		Total:   total,
+		Monthly: divide(total, int64(count)),
+	}
+}
+
+// divide returns m / n rounded half away from zero to the minor unit.
+func divide(m domain.Money, n int64) domain.Money {
+	q, r := m.Minor/n, m.Minor%n
+	if 2*r >= n {
+		q++
+	} else if 2*r <= -n {
+		q--
+	}
+	return domain.NewMoney(q, m.Currency)
+}
@@ -0,0 +1,74 @@
+package payment
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// binCache is a size-bounded LRU cache of BIN lookups whose entries expire after ttl.
+type binCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type binCacheEntry struct {
+	bin       string
+	details   domain.BinDetails
+	expiresAt time.Time
+}
+
+func newBinCache(size int, ttl time.Duration) *binCache {
+	return &binCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *binCache) get(bin string) (domain.BinDetails, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[bin]
+	if !ok {
+		return domain.BinDetails{}, false
+	}
+	entry := elem.Value.(*binCacheEntry)
+	if c.ttl > 0 && !c.now().Before(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, bin)
+		return domain.BinDetails{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.details, true
+}
+
+func (c *binCache) put(bin string, details domain.BinDetails) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+	if elem, ok := c.entries[bin]; ok {
+		entry := elem.Value.(*binCacheEntry)
+		entry.details = details
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[bin] = c.order.PushFront(&binCacheEntry{bin: bin, details: details, expiresAt: expiresAt})
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*binCacheEntry).bin)
+	}
+}
@@ -0,0 +1,206 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/streamerd/paytr-go/config"
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// ErrInvalidNotificationHash is returned when the hash of a payment or transfer notification
+// does not match the one computed with the merchant's credentials.
+var ErrInvalidNotificationHash = errors.New("paytr: invalid notification hash")
+
+// NotificationFunc is called by CallbackHandler for every verified payment notification.
+// Returning an error makes the handler answer with a non-OK response so PayTR retries the notification.
+type NotificationFunc func(n domain.PaymentNotification) error
+
+// CallbackHandler is an http.Handler for PayTR's payment notification (callback) URL.
+// It parses the POSTed notification, verifies its hash, hands it to the user-supplied
+// NotificationFunc and replies with the literal "OK" PayTR requires.
+type CallbackHandler struct {
+	config   config.PayTRConfig
+	onNotify NotificationFunc
+}
+
+// NewCallbackHandler creates a CallbackHandler that verifies notifications with the given
+// configuration and passes them to onNotify.
+func NewCallbackHandler(config config.PayTRConfig, onNotify NotificationFunc) *CallbackHandler {
+	return &CallbackHandler{
+		config:   config,
+		onNotify: onNotify,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n, err := ParseNotification(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := VerifyNotification(h.config, n); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.onNotify != nil {
+		if err := h.onNotify(n); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("OK"))
+}
+
+// ParseNotification reads a payment notification from the form fields of r.
+// It returns an error if the form cannot be parsed or a mandatory field is missing.
+func ParseNotification(r *http.Request) (domain.PaymentNotification, error) {
+	if err := r.ParseForm(); err != nil {
+		return domain.PaymentNotification{}, fmt.Errorf("error parsing notification: %v", err)
+	}
+
+	n := domain.PaymentNotification{
+		MerchantOid:      r.PostForm.Get("merchant_oid"),
+		Status:           r.PostForm.Get("status"),
+		TotalAmount:      r.PostForm.Get("total_amount"),
+		Hash:             r.PostForm.Get("hash"),
+		FailedReasonCode: r.PostForm.Get("failed_reason_code"),
+		FailedReasonMsg:  r.PostForm.Get("failed_reason_msg"),
+		TestMode:         r.PostForm.Get("test_mode"),
+		PaymentType:      r.PostForm.Get("payment_type"),
+		Currency:         r.PostForm.Get("currency"),
+		PaymentAmount:    r.PostForm.Get("payment_amount"),
+		InstallmentCount: r.PostForm.Get("installment_count"),
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"merchant_oid", n.MerchantOid},
+		{"status", n.Status},
+		{"total_amount", n.TotalAmount},
+		{"hash", n.Hash},
+	} {
+		if field.value == "" {
+			return domain.PaymentNotification{}, fmt.Errorf("missing notification field: %s", field.name)
+		}
+	}
+
+	return n, nil
+}
+
+// VerifyNotification checks the hash of a payment notification. PayTR computes it as
+// base64(HMAC-SHA256(merchant_oid + merchant_salt + status + total_amount, merchant_key)).
+func VerifyNotification(config config.PayTRConfig, n domain.PaymentNotification) error {
+	hmacStr := n.MerchantOid + config.MerchantSalt + n.Status + n.TotalAmount
+	h := hmac.New(sha256.New, []byte(config.MerchantKey))
+	h.Write([]byte(hmacStr))
+	expected := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(n.Hash)) {
+		return ErrInvalidNotificationHash
+	}
+	return nil
+}
+
+// TransferNotificationFunc is called by TransferCallbackHandler for every verified transfer result.
+// Returning an error makes the handler answer with a non-OK response so PayTR retries the notification.
+type TransferNotificationFunc func(n domain.TransferNotification) error
+
+// TransferCallbackHandler is an http.Handler for PayTR's platform transfer result URL.
+// It parses the POSTed list of completed transfers, verifies its hash, hands it to the
+// user-supplied TransferNotificationFunc and replies with the literal "OK" PayTR requires.
+type TransferCallbackHandler struct {
+	config   config.PayTRConfig
+	onNotify TransferNotificationFunc
+}
+
+// NewTransferCallbackHandler creates a TransferCallbackHandler that verifies notifications
+// with the given configuration and passes them to onNotify.
+func NewTransferCallbackHandler(config config.PayTRConfig, onNotify TransferNotificationFunc) *TransferCallbackHandler {
+	return &TransferCallbackHandler{
+		config:   config,
+		onNotify: onNotify,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *TransferCallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n, err := ParseTransferNotification(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := VerifyTransferNotification(h.config, n); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.onNotify != nil {
+		if err := h.onNotify(n); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("OK"))
+}
+
+// ParseTransferNotification reads a platform transfer result from the form fields of r.
+// PayTR posts the completed transfers as a JSON encoded list of trans_id values.
+func ParseTransferNotification(r *http.Request) (domain.TransferNotification, error) {
+	if err := r.ParseForm(); err != nil {
+		return domain.TransferNotification{}, fmt.Errorf("error parsing notification: %v", err)
+	}
+
+	n := domain.TransferNotification{
+		RawTransIDs: r.PostForm.Get("trans_ids"),
+		Hash:        r.PostForm.Get("hash"),
+	}
+	if n.RawTransIDs == "" {
+		return domain.TransferNotification{}, fmt.Errorf("missing notification field: trans_ids")
+	}
+	if n.Hash == "" {
+		return domain.TransferNotification{}, fmt.Errorf("missing notification field: hash")
+	}
+	if err := json.Unmarshal([]byte(n.RawTransIDs), &n.TransIDs); err != nil {
+		return domain.TransferNotification{}, fmt.Errorf("error parsing trans_ids: %v", err)
+	}
+
+	return n, nil
+}
+
+// VerifyTransferNotification checks the hash of a platform transfer result. PayTR computes it as
+// base64(HMAC-SHA256(trans_ids + merchant_salt, merchant_key)) over the posted trans_ids field.
+func VerifyTransferNotification(config config.PayTRConfig, n domain.TransferNotification) error {
+	h := hmac.New(sha256.New, []byte(config.MerchantKey))
+	h.Write([]byte(n.RawTransIDs + config.MerchantSalt))
+	expected := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(n.Hash)) {
+		return ErrInvalidNotificationHash
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RequestEncoder turns a request payload into the body of the HTTP request sent to PayTR.
+type RequestEncoder interface {
+	// ContentType returns the value of the Content-Type header for encoded bodies.
+	ContentType() string
+
+	// Encode serializes the request payload.
+	Encode(req interface{}) ([]byte, error)
+}
+
+var (
+	// FormEncoder encodes requests as application/x-www-form-urlencoded POST fields,
+	// which is the format PayTR's endpoints expect. It is the default encoder.
+	FormEncoder RequestEncoder = formEncoder{}
+
+	// JSONEncoder encodes requests as JSON documents.
+	JSONEncoder RequestEncoder = jsonEncoder{}
+)
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (jsonEncoder) Encode(req interface{}) ([]byte, error) {
+	return json.Marshal(req)
+}
+
+// formEncoder derives field names from `form` struct tags, falling back to `json` tags,
+// and honours the "omitempty" option and "-" names the same way encoding/json does.
+// Fields of embedded structs are promoted unless an outer field uses the same name.
+// Float fields are formatted with two decimals, matching the amounts used in PayTR tokens.
+type formEncoder struct{}
+
+func (formEncoder) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+func (formEncoder) Encode(req interface{}) ([]byte, error) {
+	v := reflect.ValueOf(req)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("form encoder: nil request")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("form encoder: unsupported request type %s", v.Type())
+	}
+
+	values := url.Values{}
+	if err := encodeFormStruct(v, values, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+// encodeFormStruct adds the fields of v to values. Names listed in hidden are
+// shadowed by an outer struct and are skipped.
+func encodeFormStruct(v reflect.Value, values url.Values, hidden map[string]bool) error {
+	t := v.Type()
+
+	// Collect the names declared at this level first so they shadow embedded fields.
+	names := map[string]bool{}
+	for name := range hidden {
+		names[name] = true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+		if name, _, ok := formFieldName(f); ok {
+			names[name] = true
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := encodeFormStruct(fv, values, names); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, omitEmpty, ok := formFieldName(f)
+		if !ok || hidden[name] {
+			continue
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		value, err := formValue(fv)
+		if err != nil {
+			return fmt.Errorf("form encoder: field %s: %v", f.Name, err)
+		}
+		values.Set(name, value)
+	}
+	return nil
+}
+
+// formFieldName returns the form field name of f and whether it has the omitempty option.
+// ok is false for unexported fields and fields tagged "-".
+func formFieldName(f reflect.StructField) (name string, omitEmpty bool, ok bool) {
+	if !f.IsExported() {
+		return "", false, false
+	}
+
+	tag, found := f.Tag.Lookup("form")
+	if !found {
+		tag = f.Tag.Get("json")
+	}
+	if tag == "-" {
+		return "", false, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, true
+}
+
+// formValue renders a single field value. Values that have no natural string
+// form, such as slices and maps, are sent as JSON.
+func formValue(v reflect.Value) (string, error) {
+	if s, ok := v.Interface().(fmt.Stringer); ok && v.Kind() != reflect.String {
+		return s.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return "1", nil
+		}
+		return "0", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', 2, 64), nil
+	default:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
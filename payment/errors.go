@@ -0,0 +1,204 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// ErrorKind classifies an Error so callers can decide whether to retry,
+// fix the request or show a message to the customer.
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown is used when the failure could not be classified.
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindValidation means the request was rejected because of invalid or missing fields.
+	ErrorKindValidation
+	// ErrorKindAuth means the merchant credentials or the paytr_token were rejected.
+	ErrorKindAuth
+	// ErrorKindDeclined means the payment was declined by the bank, 3D Secure or fraud checks.
+	ErrorKindDeclined
+	// ErrorKindRetryable means the failure is transient and the request may be sent again.
+	ErrorKindRetryable
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindValidation:
+		return "validation"
+	case ErrorKindAuth:
+		return "auth"
+	case ErrorKindDeclined:
+		return "declined"
+	case ErrorKindRetryable:
+		return "retryable"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is returned by Service methods when a request fails, either in transport,
+// with an unexpected HTTP status or with a non-success status from PayTR.
+type Error struct {
+	Endpoint   domain.Endpoint // The endpoint the request was sent to.
+	HTTPStatus int             // The HTTP status code, or 0 if no response was received.
+	Status     string          // The status reported by PayTR, e.g. "failed" or "error".
+	ErrNo      string          // PayTR's err_no, if any.
+	ErrMsg     string          // PayTR's err_msg or message, if any.
+	Reason     string          // PayTR's reason, if any.
+	Kind       ErrorKind       // The classification of the failure.
+	Err        error           // The underlying transport or decoding error, if any.
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "paytr: %s", e.Endpoint)
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+		return b.String()
+	}
+	if e.HTTPStatus != 0 && (e.HTTPStatus < 200 || e.HTTPStatus > 299) {
+		fmt.Fprintf(&b, ": HTTP %d", e.HTTPStatus)
+	}
+	if e.Status != "" {
+		fmt.Fprintf(&b, ": %s", e.Status)
+	}
+	if msg := e.Message(); msg != "" {
+		fmt.Fprintf(&b, ": %s", msg)
+	}
+	if e.ErrNo != "" {
+		fmt.Fprintf(&b, " (err_no %s)", e.ErrNo)
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying transport or decoding error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Message returns the most descriptive message PayTR gave for the failure.
+func (e *Error) Message() string {
+	if e.ErrMsg != "" {
+		return e.ErrMsg
+	}
+	if e.Reason != "" {
+		return e.Reason
+	}
+	if code, ok := LookupErrorCode(e.ErrNo); ok {
+		return code.Description
+	}
+	return ""
+}
+
+// Retryable reports whether the request may safely be sent again.
+func (e *Error) Retryable() bool {
+	return e.Kind == ErrorKindRetryable
+}
+
+// ErrorCode describes a known PayTR error code.
+type ErrorCode struct {
+	Code        string
+	Kind        ErrorKind
+	Description string
+}
+
+// ErrorCodes is the catalog of known PayTR failure codes, as sent in err_no and
+// in the failed_reason_code of payment notifications.
+var ErrorCodes = map[string]ErrorCode{
+	"0":  {"0", ErrorKindDeclined, "Payment was not approved; see the detailed error message"},
+	"1":  {"1", ErrorKindDeclined, "Authentication was not completed by the customer"},
+	"2":  {"2", ErrorKindDeclined, "3D Secure authentication failed"},
+	"3":  {"3", ErrorKindDeclined, "Payment was not approved by security checks"},
+	"6":  {"6", ErrorKindDeclined, "Customer abandoned the payment page"},
+	"8":  {"8", ErrorKindValidation, "Installments are not allowed for this card"},
+	"9":  {"9", ErrorKindDeclined, "This card is not authorized for the transaction"},
+	"10": {"10", ErrorKindValidation, "3D Secure must be used for this transaction"},
+	"11": {"11", ErrorKindDeclined, "Security warning; verify the customer"},
+	"99": {"99", ErrorKindValidation, "Technical integration error"},
+}
+
+// LookupErrorCode returns the catalog entry for a PayTR error code.
+func LookupErrorCode(code string) (ErrorCode, bool) {
+	c, ok := ErrorCodes[strings.TrimSpace(code)]
+	return c, ok
+}
+
+// newTransportError wraps an error that occurred before a response was received.
+func newTransportError(endpoint domain.Endpoint, err error) *Error {
+	return &Error{
+		Endpoint: endpoint,
+		Kind:     classifyTransportError(err),
+		Err:      err,
+	}
+}
+
+// newStatusError builds an Error from a non-success PayTR response.
+func newStatusError(endpoint domain.Endpoint, httpStatus int, status, errNo, errMsg, reason string) *Error {
+	e := &Error{
+		Endpoint:   endpoint,
+		HTTPStatus: httpStatus,
+		Status:     status,
+		ErrNo:      errNo,
+		ErrMsg:     errMsg,
+		Reason:     reason,
+	}
+	e.Kind = classifyStatusError(e)
+	return e
+}
+
+func classifyTransportError(err error) ErrorKind {
+	if errors.Is(err, context.Canceled) {
+		return ErrorKindUnknown
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorKindRetryable
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorKindRetryable
+	}
+	return ErrorKindUnknown
+}
+
+func classifyStatusError(e *Error) ErrorKind {
+	switch {
+	case e.HTTPStatus >= 500, e.HTTPStatus == 429, e.HTTPStatus == 408:
+		return ErrorKindRetryable
+	case e.HTTPStatus == 401, e.HTTPStatus == 403:
+		return ErrorKindAuth
+	case e.HTTPStatus >= 400 && e.HTTPStatus < 500:
+		return ErrorKindValidation
+	}
+
+	if code, ok := LookupErrorCode(e.ErrNo); ok {
+		return code.Kind
+	}
+
+	msg := strings.ToLower(e.ErrMsg + " " + e.Reason)
+	switch {
+	case strings.Contains(msg, "paytr_token"), strings.Contains(msg, "merchant_id"), strings.Contains(msg, "hash"):
+		return ErrorKindAuth
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "zaman aşımı"), strings.Contains(msg, "try again"):
+		return ErrorKindRetryable
+	case strings.Contains(msg, "required"), strings.Contains(msg, "invalid"), strings.Contains(msg, "gecersiz"),
+		strings.Contains(msg, "geçersiz"), strings.Contains(msg, "exceeds"), strings.Contains(msg, "not found"):
+		return ErrorKindValidation
+	case strings.Contains(msg, "declined"), strings.Contains(msg, "insufficient"), strings.Contains(msg, "yetersiz"),
+		strings.Contains(msg, "onaylanmadı"):
+		return ErrorKindDeclined
+	}
+	return ErrorKindUnknown
+}
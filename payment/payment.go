@@ -26,17 +26,22 @@ package payment
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
+	"github.com/streamerd/paytr-go/card"
 	"github.com/streamerd/paytr-go/config"
 	"github.com/streamerd/paytr-go/domain"
 )
@@ -48,6 +53,7 @@ type HTTPClient interface {
 
 // Service defines the operations available for interacting with the PayTR API,
 // including payment processing and card management.
+// Failed requests, including responses with a non-success PayTR status, are reported as *Error.
 type Service interface {
 
 	// NewCardPayment processes a new card payment using the provided request data.
@@ -58,6 +64,9 @@ type Service interface {
 	//   - An error if the payment processing fails.
 	NewCardPayment(req domain.NewCardPaymentRequest) (*domain.PayTRResponse, error)
 
+	// NewCardPaymentContext is like NewCardPayment but uses ctx for cancellation and deadlines.
+	NewCardPaymentContext(ctx context.Context, req domain.NewCardPaymentRequest) (*domain.PayTRResponse, error)
+
 	// SavedCardPayment processes a payment using a previously saved card.
 	// Parameters:
 	//   - req: A SavedCardPaymentRequest struct containing details of the saved card payment.
@@ -66,6 +75,9 @@ type Service interface {
 	//   - An error if the payment processing fails.
 	SavedCardPayment(req domain.SavedCardPaymentRequest) (*domain.PayTRResponse, error)
 
+	// SavedCardPaymentContext is like SavedCardPayment but uses ctx for cancellation and deadlines.
+	SavedCardPaymentContext(ctx context.Context, req domain.SavedCardPaymentRequest) (*domain.PayTRResponse, error)
+
 	// RecurringPayment processes a recurring payment using a saved card.
 	// Parameters:
 	//   - req: A SavedCardPaymentRequest struct containing details of the recurring payment.
@@ -74,7 +86,26 @@ type Service interface {
 	//   - An error if the payment processing fails.
 	RecurringPayment(req domain.SavedCardPaymentRequest) (*domain.PayTRResponse, error)
 
-	// RefundPayment refunds a payment by the specified amount.
+	// RecurringPaymentContext is like RecurringPayment but uses ctx for cancellation and deadlines.
+	RecurringPaymentContext(ctx context.Context, req domain.SavedCardPaymentRequest) (*domain.PayTRResponse, error)
+
+	// IFrameToken requests an iFrame API token used to display PayTR's hosted payment form.
+	// Parameters:
+	//   - req: An IFrameTokenRequest struct containing the order, basket and installment details.
+	// Returns:
+	//   - An IFrameTokenResponse containing the token and the URL to load in the iframe.
+	//   - An error if the token request fails or PayTR rejects it.
+	IFrameToken(req domain.IFrameTokenRequest) (*domain.IFrameTokenResponse, error)
+
+	// IFrameTokenContext is like IFrameToken but uses ctx for cancellation and deadlines.
+	IFrameTokenContext(ctx context.Context, req domain.IFrameTokenRequest) (*domain.IFrameTokenResponse, error)
+
+	// RefundPayment refunds a payment by the specified amount. Refunds exceeding the remaining
+	// refundable amount are rejected before they are sent, see SetRefundCheck. A unique ReferenceNo
+	// is generated when none is given, so that every partial refund can be traced; it is returned
+	// in the response data as "reference_no". Refunds are never retried, and a generated reference
+	// differs on every call: to retry a refund whose outcome is unknown, e.g. after a timeout, set
+	// ReferenceNo yourself and send the same one again, or check GetRefunds first.
 	// Parameters:
 	//   - req: A RefundRequest struct containing details of the refund, including the amount to refund.
 	// Returns:
@@ -82,14 +113,48 @@ type Service interface {
 	//   - An error if the refund process fails.
 	RefundPayment(req domain.RefundRequest) (*domain.PayTRResponse, error)
 
+	// RefundPaymentContext is like RefundPayment but uses ctx for cancellation and deadlines.
+	RefundPaymentContext(ctx context.Context, req domain.RefundRequest) (*domain.PayTRResponse, error)
+
+	// GetRefunds retrieves the refunds of a payment with MerchantStatusInquiry and computes
+	// the amount that can still be refunded.
+	// Parameters:
+	//   - merchantOid: The merchant order ID of the payment.
+	// Returns:
+	//   - A RefundSummary with the paid, refunded and remaining amounts and every refund.
+	//   - An error if the status inquiry fails or its returns cannot be parsed.
+	GetRefunds(merchantOid string) (*domain.RefundSummary, error)
+
+	// GetRefundsContext is like GetRefunds but uses ctx for cancellation and deadlines.
+	GetRefundsContext(ctx context.Context, merchantOid string) (*domain.RefundSummary, error)
+
 	// GetTransactionDetails retrieves details for a transaction within the given date range.
 	// Parameters:
 	//   - req: A TransactionDetailsRequest struct specifying the date range and transaction details to query.
 	// Returns:
-	//   - A TransactionDetailsResponse containing the transaction details.
+	//   - A TransactionDetailsResponse containing the transaction details. PayTR reports a period
+	//     without transactions with the status "failed", which is returned with no transactions.
 	//   - An error if the request for transaction details fails.
 	GetTransactionDetails(req domain.TransactionDetailsRequest) (*domain.TransactionDetailsResponse, error)
 
+	// GetTransactionDetailsContext is like GetTransactionDetails but uses ctx for cancellation and deadlines.
+	GetTransactionDetailsContext(ctx context.Context, req domain.TransactionDetailsRequest) (*domain.TransactionDetailsResponse, error)
+
+	// GetTransactionDetailsRange retrieves the transactions of any period by splitting it into
+	// the windows PayTR's report accepts and querying them concurrently, as set by SetReportPolicy.
+	// Bounds are converted to Europe/Istanbul time, which PayTR's reports use.
+	// Parameters:
+	//   - from: The start of the period, inclusive.
+	//   - to: The end of the period, exclusive.
+	// Returns:
+	//   - An iterator over the transactions, in date order. The windows do not overlap, so each
+	//     transaction is yielded once. If a window cannot be retrieved, the iterator yields the
+	//     error and stops. Stopping the iteration early cancels the requests in flight.
+	GetTransactionDetailsRange(from, to time.Time) iter.Seq2[domain.Transaction, error]
+
+	// GetTransactionDetailsRangeContext is like GetTransactionDetailsRange but uses ctx for cancellation and deadlines.
+	GetTransactionDetailsRangeContext(ctx context.Context, from, to time.Time) iter.Seq2[domain.Transaction, error]
+
 	// MerchantStatusInquiry inquires about the status of a merchant transaction.
 	// Parameters:
 	//   - req: A StatusInquiryRequest struct specifying the details of the merchant transaction to inquire about.
@@ -98,6 +163,9 @@ type Service interface {
 	//   - An error if the status inquiry process fails.
 	MerchantStatusInquiry(req domain.StatusInquiryRequest) (*domain.StatusInquiryResponse, error)
 
+	// MerchantStatusInquiryContext is like MerchantStatusInquiry but uses ctx for cancellation and deadlines.
+	MerchantStatusInquiryContext(ctx context.Context, req domain.StatusInquiryRequest) (*domain.StatusInquiryResponse, error)
+
 	// AddNewCard saves a new card to the user's account.
 	// Parameters:
 	//   - req: An AddNewCardRequest struct containing the card details to be saved.
@@ -106,21 +174,66 @@ type Service interface {
 	//   - An error if the card saving process fails.
 	AddNewCard(req domain.AddNewCardRequest) (*domain.PayTRResponse, error)
 
+	// AddNewCardContext is like AddNewCard but uses ctx for cancellation and deadlines.
+	AddNewCardContext(ctx context.Context, req domain.AddNewCardRequest) (*domain.PayTRResponse, error)
+
 	// GetSavedCards retrieves the list of saved cards for a given user token.
 	// Parameters:
 	//   - utoken: A string representing the user's token, used to identify the user and fetch saved cards.
 	// Returns:
-	//   - A PayTRResponse containing the list of saved cards.
+	//   - The saved cards of the user, with UToken set to utoken.
 	//   - An error if the retrieval process fails.
-	GetSavedCards(utoken string) (*domain.PayTRResponse, error)
+	GetSavedCards(utoken string) ([]domain.SavedCard, error)
+
+	// GetSavedCardsContext is like GetSavedCards but uses ctx for cancellation and deadlines.
+	GetSavedCardsContext(ctx context.Context, utoken string) ([]domain.SavedCard, error)
 
 	// GetBinDetails retrieves details about a BIN (Bank Identification Number).
+	// Results are served from the BIN cache when it is enabled with SetBinCache.
 	// Parameters:
 	//   - binNumber: A string representing the BIN (first 6-8 digits of a card) to retrieve details for.
 	// Returns:
-	//   - A PayTRResponse containing BIN details such as the bank and card type.
+	//   - A BinDetails containing the bank, brand, card type, card family and allowed payment options.
 	//   - An error if the BIN lookup process fails.
-	GetBinDetails(binNumber string) (*domain.PayTRResponse, error)
+	GetBinDetails(binNumber string) (*domain.BinDetails, error)
+
+	// GetBinDetailsContext is like GetBinDetails but uses ctx for cancellation and deadlines.
+	GetBinDetailsContext(ctx context.Context, binNumber string) (*domain.BinDetails, error)
+
+	// GetInstallmentRates retrieves the merchant's installment rates for every card family.
+	// The installment package turns them into the installment options of an amount.
+	// Returns:
+	//   - An InstallmentRates holding a rate table per card family, keyed like BinDetails.CardFamily.
+	//   - An error if the rates could not be retrieved.
+	GetInstallmentRates() (*domain.InstallmentRates, error)
+
+	// GetInstallmentRatesContext is like GetInstallmentRates but uses ctx for cancellation and deadlines.
+	GetInstallmentRatesContext(ctx context.Context) (*domain.InstallmentRates, error)
+
+	// PlatformTransfer sends a submerchant's share of a marketplace payment to the submerchant's IBAN.
+	// The request is validated with ValidatePlatformTransfer before anything is sent, and is never
+	// retried automatically. Completed transfers are reported to the platform transfer result URL,
+	// see TransferCallbackHandler.
+	// Parameters:
+	//   - req: A PlatformTransferRequest with the order, a unique trans_id, the amounts and the recipient.
+	// Returns:
+	//   - A PayTRResponse confirming that PayTR accepted the transfer.
+	//   - An error if the transfer was rejected.
+	PlatformTransfer(req domain.PlatformTransferRequest) (*domain.PayTRResponse, error)
+
+	// PlatformTransferContext is like PlatformTransfer but uses ctx for cancellation and deadlines.
+	PlatformTransferContext(ctx context.Context, req domain.PlatformTransferRequest) (*domain.PayTRResponse, error)
+
+	// GetReturnedTransfers retrieves the transfers that the receiving banks sent back in a period.
+	// Parameters:
+	//   - req: A ReturnedTransfersRequest with the start and end dates of the period.
+	// Returns:
+	//   - The returned transfers, which is empty if there were none.
+	//   - An error if the query fails.
+	GetReturnedTransfers(req domain.ReturnedTransfersRequest) ([]domain.ReturnedTransfer, error)
+
+	// GetReturnedTransfersContext is like GetReturnedTransfers but uses ctx for cancellation and deadlines.
+	GetReturnedTransfersContext(ctx context.Context, req domain.ReturnedTransfersRequest) ([]domain.ReturnedTransfer, error)
 
 	// DeleteSavedCard removes a saved card using the provided user and card tokens.
 	// Parameters:
@@ -130,68 +243,308 @@ type Service interface {
 	//   - A PayTRResponse confirming the success or failure of the card deletion process.
 	//   - An error if the card deletion process fails.
 	DeleteSavedCard(utoken, ctoken string) (*domain.PayTRResponse, error)
+
+	// DeleteSavedCardContext is like DeleteSavedCard but uses ctx for cancellation and deadlines.
+	DeleteSavedCardContext(ctx context.Context, utoken, ctoken string) (*domain.PayTRResponse, error)
 	SetHTTPClient(client HTTPClient)
+
+	// SetRequestEncoder replaces the encoder used for request bodies. FormEncoder is used by default.
+	SetRequestEncoder(encoder RequestEncoder)
+
+	// SetRetryPolicy replaces the retry policy applied to read-only operations
+	// (MerchantStatusInquiry, GetTransactionDetails, GetSavedCards, GetBinDetails, GetInstallmentRates and GetReturnedTransfers).
+	// DefaultRetryPolicy is used by default; NoRetry disables retries.
+	SetRetryPolicy(policy RetryPolicy)
+
+	// SetReconcilePolicy enables reconciliation of payments that fail ambiguously, such as
+	// on a timeout: the outcome is resolved with MerchantStatusInquiry instead of returning
+	// the error. Reconciliation is disabled by default; see DefaultReconcilePolicy.
+	SetReconcilePolicy(policy ReconcilePolicy)
+
+	// SetBinCache enables an in-memory LRU cache for GetBinDetails holding up to size BINs
+	// for ttl each. A size of zero disables the cache, which is the default.
+	SetBinCache(size int, ttl time.Duration)
+
+	// SetReportPolicy sets the window size and concurrency of GetTransactionDetailsRange.
+	// DefaultReportPolicy is used by default.
+	SetReportPolicy(policy ReportPolicy)
+
+	// SetRefundCheck sets whether RefundPayment looks the payment up with GetRefunds first and
+	// rejects refunds exceeding the remaining refundable amount before they are sent. It is
+	// enabled by default; disabling it saves a status inquiry per refund.
+	// The check does not hold a lock between the inquiry and the refund: two refunds of the
+	// same merchant_oid sent concurrently may both pass it, so callers that refund concurrently
+	// must serialize refunds per merchant_oid themselves.
+	SetRefundCheck(enabled bool)
+
+	// SetPaymentRepository makes the service record every payment attempt and drive it through
+	// the lifecycle described by domain.PaymentStatus, keyed by merchant_oid, with the customer
+	// email as UserID. A payment is stored as created before it is sent; if that fails the payment
+	// is not sent. Refunds of payments that cannot be refunded are rejected before they are sent.
+	// Later updates are best effort, as the payment has already been processed. Nil disables recording.
+	SetPaymentRepository(repo domain.PaymentRepository)
+
+	// SetCardRepository makes the service record cards saved with store_card or AddNewCard,
+	// keep them in sync with GetSavedCards and forget them on DeleteSavedCard.
+	// Updates are best effort. Nil disables recording.
+	SetCardRepository(repo domain.CardRepository)
+
+	// ApplyNotification verifies a payment notification and moves the recorded payment to
+	// succeeded or failed accordingly. Repeated notifications are accepted.
+	// Parameters:
+	//   - n: A PaymentNotification, usually received by a CallbackHandler.
+	// Returns:
+	//   - An error if the hash is invalid, the payment was not recorded (domain.ErrNotFound),
+	//     the lifecycle does not allow the move (domain.ErrIllegalTransition), or no payment
+	//     repository is configured (ErrNoPaymentRepository).
+	ApplyNotification(n domain.PaymentNotification) error
+
+	// ApplyNotificationContext is like ApplyNotification but uses ctx for cancellation and deadlines.
+	ApplyNotificationContext(ctx context.Context, n domain.PaymentNotification) error
+
+	// ExpirePayment gives up on a recorded payment whose outcome never arrived, such as a 3D Secure
+	// payment the customer abandoned. It is meant to be called by a periodic job.
+	// Parameters:
+	//   - merchantOid: The merchant order ID of the payment.
+	// Returns:
+	//   - An error if the payment was not recorded, already has an outcome (domain.ErrIllegalTransition),
+	//     or no payment repository is configured.
+	ExpirePayment(merchantOid string) error
+
+	// ExpirePaymentContext is like ExpirePayment but uses ctx for cancellation and deadlines.
+	ExpirePaymentContext(ctx context.Context, merchantOid string) error
 }
 
 type service struct {
-	config config.PayTRConfig
-	client HTTPClient
+	config          config.PayTRConfig
+	client          HTTPClient
+	encoder         RequestEncoder
+	retry           RetryPolicy
+	reconcile       ReconcilePolicy
+	bins            *binCache
+	report          ReportPolicy
+	skipRefundCheck bool
+	payments        domain.PaymentRepository
+	cards           domain.CardRepository
 }
 
 func (s *service) SetHTTPClient(client HTTPClient) {
 	s.client = client
 }
 
+func (s *service) SetRequestEncoder(encoder RequestEncoder) {
+	s.encoder = encoder
+}
+
+func (s *service) SetRetryPolicy(policy RetryPolicy) {
+	s.retry = policy
+}
+
+func (s *service) SetReconcilePolicy(policy ReconcilePolicy) {
+	s.reconcile = policy
+}
+
+func (s *service) SetBinCache(size int, ttl time.Duration) {
+	if size <= 0 {
+		s.bins = nil
+		return
+	}
+	s.bins = newBinCache(size, ttl)
+}
+
+func (s *service) SetReportPolicy(policy ReportPolicy) {
+	s.report = policy
+}
+
+func (s *service) SetRefundCheck(enabled bool) {
+	s.skipRefundCheck = !enabled
+}
+
+func (s *service) SetPaymentRepository(repo domain.PaymentRepository) {
+	s.payments = repo
+}
+
+func (s *service) SetCardRepository(repo domain.CardRepository) {
+	s.cards = repo
+}
+
 // NewService creates a new PayTR service with the provided configuration and repository.
+// Requests whose context has no deadline time out after config.RequestTimeout().
 func NewService(config config.PayTRConfig) Service {
 	return &service{
-		config: config,
-		client: &http.Client{Timeout: 10 * time.Second},
+		config:  config,
+		client:  &http.Client{},
+		encoder: FormEncoder,
+		retry:   DefaultRetryPolicy,
+		report:  DefaultReportPolicy,
 	}
 }
 
 // PAYMENTS
 
 // NewCardPayment processes a payment using the details from the NewCardPaymentRequest.
-// The payment details are validated, and the PayTR token is generated based on the request data.
+// The payment details are validated with ValidateNewCardPayment before anything is sent,
+// the card brand is inferred when CardType is empty, and the PayTR token is generated based on the request data.
+// The merchant_id sent is always the configured one, whatever req.MerchantID holds, since the
+// token is signed with the configured merchant key and PayTR rejects it for any other merchant.
 func (s *service) NewCardPayment(req domain.NewCardPaymentRequest) (*domain.PayTRResponse, error) {
+	return s.NewCardPaymentContext(context.Background(), req)
+}
+
+func (s *service) NewCardPaymentContext(ctx context.Context, req domain.NewCardPaymentRequest) (*domain.PayTRResponse, error) {
+	req.MerchantID = s.config.MerchantID
+	req.CardNumber = card.Normalize(req.CardNumber)
+	if req.CardType == "" {
+		req.CardType = string(card.DetectBrand(req.CardNumber))
+	}
+	if err := ValidateNewCardPayment(req); err != nil {
+		return nil, validationError(domain.EndpointPayment, err)
+	}
 	req.PayTRToken = s.generateToken(req.CommonPaymentRequest)
-	return s.sendRequest(req, domain.PayTRBaseURL+"/odeme")
+
+	resp, err := s.sendPayment(ctx, req, req.CommonPaymentRequest, domain.PaymentMethodCard)
+	if err == nil && req.StoreCard == "1" {
+		s.recordNewCard(ctx, req, resp)
+	}
+	return resp, err
 }
 
 func (s *service) SavedCardPayment(req domain.SavedCardPaymentRequest) (*domain.PayTRResponse, error) {
+	return s.SavedCardPaymentContext(context.Background(), req)
+}
+
+func (s *service) SavedCardPaymentContext(ctx context.Context, req domain.SavedCardPaymentRequest) (*domain.PayTRResponse, error) {
+	req.MerchantID = s.config.MerchantID
+	if err := ValidateSavedCardPayment(req); err != nil {
+		return nil, validationError(domain.EndpointPayment, err)
+	}
 	req.PayTRToken = s.generateToken(req.CommonPaymentRequest)
-	return s.sendRequest(req, domain.PayTRBaseURL+"/odeme")
+	return s.sendPayment(ctx, req, req.CommonPaymentRequest, domain.PaymentMethodSavedCard)
 }
 
 func (s *service) RecurringPayment(req domain.SavedCardPaymentRequest) (*domain.PayTRResponse, error) {
+	return s.RecurringPaymentContext(context.Background(), req)
+}
+
+func (s *service) RecurringPaymentContext(ctx context.Context, req domain.SavedCardPaymentRequest) (*domain.PayTRResponse, error) {
+	req.MerchantID = s.config.MerchantID
 	req.RecurringPayment = "1"
+	if err := ValidateSavedCardPayment(req); err != nil {
+		return nil, validationError(domain.EndpointPayment, err)
+	}
 	req.PayTRToken = s.generateToken(req.CommonPaymentRequest)
-	return s.sendRequest(req, domain.PayTRBaseURL+"/odeme")
+	return s.sendPayment(ctx, req, req.CommonPaymentRequest, domain.PaymentMethodRecurring)
+}
+
+// IFrameToken obtains a token for PayTR's iFrame API. The basket is base64 encoded and
+// the payment amount is sent in minor units (kuruş) as the iFrame API requires.
+func (s *service) IFrameToken(req domain.IFrameTokenRequest) (*domain.IFrameTokenResponse, error) {
+	return s.IFrameTokenContext(context.Background(), req)
+}
+
+func (s *service) IFrameTokenContext(ctx context.Context, req domain.IFrameTokenRequest) (*domain.IFrameTokenResponse, error) {
+	if err := ValidateIFrameToken(req); err != nil {
+		return nil, validationError(domain.EndpointIFrameToken, err)
+	}
+
+	paytrReq := struct {
+		domain.IFrameTokenRequest
+		PaymentAmount string `json:"payment_amount"`
+		UserBasket    string `json:"user_basket"`
+	}{
+		IFrameTokenRequest: req,
+		PaymentAmount:      req.PaymentAmount.MinorString(),
+		UserBasket:         req.UserBasket.Base64(),
+	}
+	paytrReq.MerchantID = s.config.MerchantID
+
+	hashStr := s.config.MerchantID +
+		paytrReq.UserIP +
+		paytrReq.MerchantOid +
+		paytrReq.Email +
+		paytrReq.PaymentAmount +
+		paytrReq.UserBasket +
+		paytrReq.NoInstallment +
+		paytrReq.MaxInstallment +
+		paytrReq.Currency +
+		paytrReq.TestMode
+	paytrReq.PayTRToken = s.generateSimpleToken(hashStr)
+
+	if err := s.recordAttempt(ctx, domain.CommonPaymentRequest{
+		MerchantOid:   req.MerchantOid,
+		Email:         req.Email,
+		PaymentAmount: req.PaymentAmount,
+		Currency:      req.Currency,
+	}, domain.PaymentMethodIFrame); err != nil {
+		return nil, err
+	}
+
+	var result domain.IFrameTokenResponse
+	httpStatus, err := s.sendRequestInto(ctx, paytrReq, domain.EndpointIFrameToken, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Status != "success" {
+		return nil, newStatusError(domain.EndpointIFrameToken, httpStatus, result.Status, "", "", result.Reason)
+	}
+
+	result.IFrameURL = s.config.URL(domain.EndpointIFrame) + result.Token
+	return &result, nil
 }
 
 func (s *service) RefundPayment(req domain.RefundRequest) (*domain.PayTRResponse, error) {
+	return s.RefundPaymentContext(context.Background(), req)
+}
+
+func (s *service) RefundPaymentContext(ctx context.Context, req domain.RefundRequest) (*domain.PayTRResponse, error) {
+	if req.ReferenceNo == "" {
+		req.ReferenceNo = newReferenceNo()
+	}
+	if err := ValidateRefund(req); err != nil {
+		return nil, validationError(domain.EndpointRefund, err)
+	}
+
 	paytrReq := struct {
-		MerchantID   string  `json:"merchant_id"`
-		MerchantOid  string  `json:"merchant_oid"`
-		ReturnAmount float64 `json:"return_amount"`
-		PayTRToken   string  `json:"paytr_token"`
-		ReferenceNo  string  `json:"reference_no,omitempty"`
+		MerchantID   string `json:"merchant_id"`
+		MerchantOid  string `json:"merchant_oid"`
+		ReturnAmount string `json:"return_amount"`
+		PayTRToken   string `json:"paytr_token"`
+		ReferenceNo  string `json:"reference_no,omitempty"`
 	}{
 		MerchantID:   s.config.MerchantID,
 		MerchantOid:  req.MerchantOid,
-		ReturnAmount: req.ReturnAmount,
+		ReturnAmount: req.ReturnAmount.String(),
 		ReferenceNo:  req.ReferenceNo,
 	}
 
 	// Generate PayTR token
-	hashStr := fmt.Sprintf("%s%s%.2f", s.config.MerchantID, req.MerchantOid, req.ReturnAmount)
+	hashStr := s.config.MerchantID + req.MerchantOid + paytrReq.ReturnAmount
 	paytrReq.PayTRToken = s.generateSimpleToken(hashStr)
 
-	return s.sendRequest(paytrReq, domain.PayTRBaseURL+"/odeme/iade")
+	if err := s.checkRefundable(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkRemaining(ctx, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.sendRequest(ctx, paytrReq, domain.EndpointRefund)
+	if err == nil {
+		s.recordRefund(ctx, req)
+		if resp.Data == nil {
+			resp.Data = map[string]interface{}{}
+		}
+		resp.Data["reference_no"] = req.ReferenceNo
+	}
+	return resp, err
 }
 
 func (s *service) MerchantStatusInquiry(req domain.StatusInquiryRequest) (*domain.StatusInquiryResponse, error) {
+	return s.MerchantStatusInquiryContext(context.Background(), req)
+}
+
+func (s *service) MerchantStatusInquiryContext(ctx context.Context, req domain.StatusInquiryRequest) (*domain.StatusInquiryResponse, error) {
 	paytrReq := struct {
 		MerchantID  string `json:"merchant_id"`
 		MerchantOid string `json:"merchant_oid"`
@@ -203,25 +556,31 @@ func (s *service) MerchantStatusInquiry(req domain.StatusInquiryRequest) (*domai
 
 	paytrReq.PayTRToken = s.generateSimpleToken(s.config.MerchantID + req.MerchantOid)
 
-	paytrResp, err := s.sendRequest(paytrReq, domain.PayTRBaseURL+"/odeme/durum-sorgu")
+	paytrResp, err := s.sendRequest(ctx, paytrReq, domain.EndpointStatusInquiry)
 	if err != nil {
 		return nil, err
 	}
 
-	if paytrResp.Status != "success" {
-		return nil, fmt.Errorf("PayTR error: %s", paytrResp.Message)
-	}
-
 	var result domain.StatusInquiryResponse
-	err = mapstructure.Decode(paytrResp.Data, &result)
+	err = decodeData(paytrResp.Data, &result)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding response: %v", err)
+		return nil, &Error{Endpoint: domain.EndpointStatusInquiry, Err: fmt.Errorf("error decoding response: %v", err)}
 	}
 
+	switch result.Status {
+	case "success":
+		s.recordStatus(ctx, req.MerchantOid, domain.PaymentStatusSucceeded, "status inquiry")
+	case "failed":
+		s.recordStatus(ctx, req.MerchantOid, domain.PaymentStatusFailed, "status inquiry")
+	}
 	return &result, nil
 }
 
 func (s *service) GetTransactionDetails(req domain.TransactionDetailsRequest) (*domain.TransactionDetailsResponse, error) {
+	return s.GetTransactionDetailsContext(context.Background(), req)
+}
+
+func (s *service) GetTransactionDetailsContext(ctx context.Context, req domain.TransactionDetailsRequest) (*domain.TransactionDetailsResponse, error) {
 	paytrReq := struct {
 		MerchantID string `json:"merchant_id"`
 		StartDate  string `json:"start_date"`
@@ -237,15 +596,37 @@ func (s *service) GetTransactionDetails(req domain.TransactionDetailsRequest) (*
 
 	paytrReq.PayTRToken = s.generateSimpleToken(s.config.MerchantID + req.StartDate + req.EndDate)
 
-	paytrResp, err := s.sendRequest(paytrReq, domain.PayTRBaseURL+"/rapor/islem-dokumu")
+	// PayTR answers "failed" when the period has no transactions; only "error" is a failure.
+	var paytrResp domain.PayTRResponse
+	err := s.withRetry(ctx, domain.EndpointTransactionDetails, func() error {
+		paytrResp = domain.PayTRResponse{}
+		httpStatus, err := s.sendRequestInto(ctx, paytrReq, domain.EndpointTransactionDetails, &paytrResp)
+		if err != nil {
+			return err
+		}
+		if paytrResp.Status != "success" && paytrResp.Status != "failed" {
+			return newStatusError(domain.EndpointTransactionDetails, httpStatus, paytrResp.Status, paytrResp.ErrNo, firstNonEmpty(paytrResp.ErrMsg, paytrResp.Message), paytrResp.Reason)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	if paytrResp.Status == "failed" {
+		return &domain.TransactionDetailsResponse{Status: "failed"}, nil
+	}
 
 	var result domain.TransactionDetailsResponse
-	err = mapstructure.Decode(paytrResp.Data, &result)
+	err = decodeData(paytrResp.Data, &result)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding response: %v", err)
+		return nil, &Error{Endpoint: domain.EndpointTransactionDetails, Err: fmt.Errorf("error decoding response: %v", err)}
+	}
+
+	if result.Status == "error" {
+		return nil, newStatusError(domain.EndpointTransactionDetails, 0, result.Status, "", result.ErrMsg, "")
+	}
+	if result.Status == "failed" {
+		result.Transactions = nil
 	}
 
 	return &result, nil
@@ -253,7 +634,17 @@ func (s *service) GetTransactionDetails(req domain.TransactionDetailsRequest) (*
 
 // CARDS
 
-func (s *service) GetBinDetails(binNumber string) (*domain.PayTRResponse, error) {
+func (s *service) GetBinDetails(binNumber string) (*domain.BinDetails, error) {
+	return s.GetBinDetailsContext(context.Background(), binNumber)
+}
+
+func (s *service) GetBinDetailsContext(ctx context.Context, binNumber string) (*domain.BinDetails, error) {
+	if s.bins != nil {
+		if details, ok := s.bins.get(binNumber); ok {
+			return &details, nil
+		}
+	}
+
 	req := struct {
 		MerchantID string `json:"merchant_id"`
 		BinNumber  string `json:"bin_number"`
@@ -263,10 +654,113 @@ func (s *service) GetBinDetails(binNumber string) (*domain.PayTRResponse, error)
 		BinNumber:  binNumber,
 		PayTRToken: s.generateSimpleToken(binNumber + s.config.MerchantID),
 	}
-	return s.sendRequest(req, domain.PayTRBaseURL+"/odeme/api/bin-detail")
+
+	paytrResp, err := s.sendRequest(ctx, req, domain.EndpointBinDetail)
+	if err != nil {
+		return nil, err
+	}
+
+	// PayTR names the scheme "schema" and the card family "brand".
+	var raw struct {
+		Bank         string `json:"bank"`
+		BankCode     string `json:"bankCode"`
+		Schema       string `json:"schema"`
+		CardType     string `json:"cardType"`
+		Brand        string `json:"brand"`
+		BusinessCard string `json:"businessCard"`
+		AllowNon3D   string `json:"allow_non3d"`
+		Installment  string `json:"installment"`
+	}
+	if err := decodeData(paytrResp.Data, &raw); err != nil {
+		return nil, &Error{Endpoint: domain.EndpointBinDetail, Err: fmt.Errorf("error decoding response: %v", err)}
+	}
+
+	details := domain.BinDetails{
+		BIN:                binNumber,
+		Bank:               raw.Bank,
+		BankCode:           raw.BankCode,
+		Brand:              strings.ToUpper(raw.Schema),
+		CardType:           strings.ToLower(raw.CardType),
+		CardFamily:         strings.ToLower(raw.Brand),
+		BusinessCard:       isYes(raw.BusinessCard),
+		AllowNon3D:         isYes(raw.AllowNon3D),
+		InstallmentAllowed: isYes(raw.Installment),
+	}
+
+	if s.bins != nil {
+		s.bins.put(binNumber, details)
+	}
+	return &details, nil
+}
+
+func (s *service) GetInstallmentRates() (*domain.InstallmentRates, error) {
+	return s.GetInstallmentRatesContext(context.Background())
+}
+
+func (s *service) GetInstallmentRatesContext(ctx context.Context) (*domain.InstallmentRates, error) {
+	req := struct {
+		MerchantID string `json:"merchant_id"`
+		RequestID  string `json:"request_id"`
+		PayTRToken string `json:"paytr_token"`
+	}{
+		MerchantID: s.config.MerchantID,
+		RequestID:  strconv.FormatInt(time.Now().UnixNano(), 10),
+	}
+	req.PayTRToken = s.generateSimpleToken(s.config.MerchantID + req.RequestID)
+
+	// Unlike most endpoints, the rates are returned at the top level of the response,
+	// as "oranlar": {"world": {"taksit_2": "3.21", ...}, ...}.
+	var raw struct {
+		Status        string                                       `json:"status"`
+		ErrMsg        string                                       `json:"err_msg"`
+		Reason        string                                       `json:"reason"`
+		RequestID     string                                       `json:"request_id"`
+		MaxInstNonBus json.RawMessage                              `json:"max_inst_non_bus"`
+		Oranlar       map[string]map[string]domain.InstallmentRate `json:"oranlar"`
+	}
+	err := s.withRetry(ctx, domain.EndpointInstallmentRates, func() error {
+		raw.Oranlar = nil
+		httpStatus, err := s.sendRequestInto(ctx, req, domain.EndpointInstallmentRates, &raw)
+		if err != nil {
+			return err
+		}
+		if raw.Status != "success" {
+			return newStatusError(domain.EndpointInstallmentRates, httpStatus, raw.Status, "", raw.ErrMsg, raw.Reason)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rates := domain.InstallmentRates{
+		RequestID: raw.RequestID,
+		Families:  make(map[string]domain.InstallmentRateTable, len(raw.Oranlar)),
+	}
+	if limit := strings.Trim(string(raw.MaxInstNonBus), `"`); limit != "" && limit != "null" {
+		if rates.MaxInstallmentsNonBusiness, err = strconv.Atoi(limit); err != nil {
+			return nil, &Error{Endpoint: domain.EndpointInstallmentRates, Err: fmt.Errorf("error decoding response: invalid max_inst_non_bus %q", limit)}
+		}
+	}
+	for family, table := range raw.Oranlar {
+		decoded := make(domain.InstallmentRateTable, len(table))
+		for key, rate := range table {
+			count, err := strconv.Atoi(strings.TrimPrefix(key, "taksit_"))
+			if err != nil {
+				return nil, &Error{Endpoint: domain.EndpointInstallmentRates, Err: fmt.Errorf("error decoding response: unexpected key %q", key)}
+			}
+			decoded[count] = rate
+		}
+		rates.Families[strings.ToLower(family)] = decoded
+	}
+	return &rates, nil
 }
 
-func (s *service) GetSavedCards(utoken string) (*domain.PayTRResponse, error) {
+func (s *service) GetSavedCards(utoken string) ([]domain.SavedCard, error) {
+	return s.GetSavedCardsContext(context.Background(), utoken)
+}
+
+func (s *service) GetSavedCardsContext(ctx context.Context, utoken string) ([]domain.SavedCard, error) {
 	req := struct {
 		MerchantID string `json:"merchant_id"`
 		UToken     string `json:"utoken"`
@@ -276,10 +770,70 @@ func (s *service) GetSavedCards(utoken string) (*domain.PayTRResponse, error) {
 		UToken:     utoken,
 		PayTRToken: s.generateSimpleToken(utoken),
 	}
-	return s.sendRequest(req, domain.PayTRBaseURL+"/odeme/capi/list")
+
+	paytrResp, err := s.sendRequest(ctx, req, domain.EndpointCardList)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Cards []struct {
+			CToken     string `json:"ctoken"`
+			LastFour   string `json:"last_4"`
+			Month      string `json:"month"`
+			Year       string `json:"year"`
+			Bank       string `json:"c_bank"`
+			Name       string `json:"c_name"`
+			Brand      string `json:"c_brand"`
+			Type       string `json:"c_type"`
+			RequireCVV string `json:"require_cvv"`
+			Schema     string `json:"schema"`
+		} `json:"cards"`
+	}
+	if err := decodeData(paytrResp.Data, &raw); err != nil {
+		return nil, &Error{Endpoint: domain.EndpointCardList, Err: fmt.Errorf("error decoding response: %v", err)}
+	}
+
+	cards := make([]domain.SavedCard, len(raw.Cards))
+	for i, c := range raw.Cards {
+		cards[i] = domain.SavedCard{
+			UToken:     utoken,
+			CToken:     c.CToken,
+			LastFour:   c.LastFour,
+			CardType:   strings.ToLower(c.Type),
+			ExpiryDate: expiryDate(c.Month, c.Year),
+			Month:      c.Month,
+			Year:       c.Year,
+			Bank:       c.Bank,
+			OwnerName:  c.Name,
+			Brand:      strings.ToLower(c.Brand),
+			Schema:     strings.ToUpper(c.Schema),
+			RequireCVV: isYes(c.RequireCVV),
+		}
+	}
+	s.recordCards(ctx, utoken, cards)
+	return cards, nil
+}
+
+// expiryDate formats a card expiry month and year as MM/YY.
+func expiryDate(month, year string) string {
+	if month == "" || year == "" {
+		return ""
+	}
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	if len(year) == 4 {
+		year = year[2:]
+	}
+	return month + "/" + year
 }
 
 func (s *service) DeleteSavedCard(utoken, ctoken string) (*domain.PayTRResponse, error) {
+	return s.DeleteSavedCardContext(context.Background(), utoken, ctoken)
+}
+
+func (s *service) DeleteSavedCardContext(ctx context.Context, utoken, ctoken string) (*domain.PayTRResponse, error) {
 	req := struct {
 		MerchantID string `json:"merchant_id"`
 		UToken     string `json:"utoken"`
@@ -291,18 +845,37 @@ func (s *service) DeleteSavedCard(utoken, ctoken string) (*domain.PayTRResponse,
 		CToken:     ctoken,
 		PayTRToken: s.generateSimpleToken(utoken + ctoken),
 	}
-	return s.sendRequest(req, domain.PayTRBaseURL+"/odeme/capi/delete")
+
+	resp, err := s.sendRequest(ctx, req, domain.EndpointCardDelete)
+	if err == nil {
+		s.recordDeletedCard(ctx, utoken, ctoken)
+	}
+	return resp, err
 }
 
 func (s *service) AddNewCard(req domain.AddNewCardRequest) (*domain.PayTRResponse, error) {
+	return s.AddNewCardContext(context.Background(), req)
+}
+
+func (s *service) AddNewCardContext(ctx context.Context, req domain.AddNewCardRequest) (*domain.PayTRResponse, error) {
+	req.CardNumber = card.Normalize(req.CardNumber)
+	if req.CardType == "" {
+		req.CardType = string(card.DetectBrand(req.CardNumber))
+	}
+
+	if err := ValidateAddNewCard(req); err != nil {
+		return nil, validationError(domain.EndpointPayment, err)
+	}
+
 	// Prepare the request for adding a new card
+	amount := domain.NewMoney(100, "TRY") // Minimal amount for card validation
 	paytrReq := domain.NewCardPaymentRequest{
 		CommonPaymentRequest: domain.CommonPaymentRequest{
 			MerchantID:       s.config.MerchantID,
 			UserIP:           req.UserIP,
 			MerchantOid:      req.MerchantOid,
 			Email:            req.Email,
-			PaymentAmount:    1, // Minimal amount for card validation
+			PaymentAmount:    amount,
 			PaymentType:      "card",
 			Currency:         "TRY",
 			TestMode:         "1",
@@ -312,7 +885,7 @@ func (s *service) AddNewCard(req domain.AddNewCardRequest) (*domain.PayTRRespons
 			UserName:         req.CardOwner,
 			UserAddress:      req.UserAddress,
 			UserPhone:        req.UserPhone,
-			UserBasket:       `[["Card Validation", "1", 1]]`,
+			UserBasket:       domain.NewBasket().Add("Card Validation", amount, 1),
 			DebugOn:          "1",
 			ClientLang:       "tr",
 			InstallmentCount: "0",
@@ -327,7 +900,12 @@ func (s *service) AddNewCard(req domain.AddNewCardRequest) (*domain.PayTRRespons
 	}
 
 	paytrReq.PayTRToken = s.generateToken(paytrReq.CommonPaymentRequest)
-	return s.sendRequest(paytrReq, domain.PayTRBaseURL+"/odeme")
+
+	resp, err := s.sendPayment(ctx, paytrReq, paytrReq.CommonPaymentRequest, domain.PaymentMethodCardValidation)
+	if err == nil {
+		s.recordNewCard(ctx, paytrReq, resp)
+	}
+	return resp, err
 }
 
 // generateToken generates an HMAC token based on the payment request and the merchant's secret key.
@@ -352,7 +930,7 @@ func (s *service) generateToken(req domain.CommonPaymentRequest) string {
 		req.UserIP,
 		req.MerchantOid,
 		req.Email,
-		strconv.FormatFloat(req.PaymentAmount, 'f', 2, 64),
+		req.PaymentAmount.String(),
 		req.PaymentType,
 		req.InstallmentCount,
 		req.Currency,
@@ -380,44 +958,134 @@ func (s *service) generateSimpleToken(data string) string {
 	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
-// sendRequest sends an HTTP POST request to the provided URL with the given request payload.
-// The request is marshaled into JSON format and sent with the appropriate content type.
+// decodeData decodes the Data map of a PayTRResponse into out, matching keys against
+// the `json` tags of out so that fields such as "payment_amount" are filled in.
+// Numbers are accepted for string fields, since PayTR is not consistent about quoting them,
+// and lists or objects found where a string is expected, such as "returns", are kept as JSON text.
+func decodeData(data map[string]interface{}, out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:          "json",
+		WeaklyTypedInput: true,
+		DecodeHook:       jsonTextHook,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(data)
+}
+
+// jsonTextHook encodes lists and objects decoded into string fields as JSON text.
+func jsonTextHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to.Kind() != reflect.String || (from.Kind() != reflect.Slice && from.Kind() != reflect.Map) {
+		return data, nil
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// sendRequest sends an HTTP POST request to the given endpoint with the given request payload.
+// Requests to read-only endpoints are retried according to the service's RetryPolicy.
+// The request is bound to ctx, so cancelling ctx or reaching its deadline aborts it.
+// The request is encoded with the service's RequestEncoder and sent with the matching content type.
 // It then reads and decodes the response into a PayTRResponse object.
 // Parameters:
-//   - req: The request payload that is marshaled into JSON and sent to the URL.
-//   - url: The endpoint to which the request is sent.
+//   - ctx: The context controlling cancellation and deadline of the request.
+//   - req: The request payload that is encoded and sent to the URL.
+//   - endpoint: The endpoint to which the request is sent.
 //
 // Returns:
 //   - A pointer to PayTRResponse containing the response data from the PayTR API.
-//   - An error if any issue occurs during the request or response processing.
-func (s *service) sendRequest(req interface{}, url string) (*domain.PayTRResponse, error) {
-	jsonData, err := json.Marshal(req)
+//   - An *Error if any issue occurs during the request or PayTR reports a non-success status.
+func (s *service) sendRequest(ctx context.Context, req interface{}, endpoint domain.Endpoint) (*domain.PayTRResponse, error) {
+	var result domain.PayTRResponse
+	err := s.withRetry(ctx, endpoint, func() error {
+		result = domain.PayTRResponse{}
+		httpStatus, err := s.sendRequestInto(ctx, req, endpoint, &result)
+		if err != nil {
+			return err
+		}
+
+		if result.Status != "success" {
+			return newStatusError(endpoint, httpStatus, result.Status, result.ErrNo, firstNonEmpty(result.ErrMsg, result.Message), result.Reason)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	return &result, nil
+}
+
+// sendRequestInto works like sendRequest but decodes the response body into out,
+// for endpoints whose response does not follow the PayTRResponse layout.
+// It returns the HTTP status code of the response.
+func (s *service) sendRequestInto(ctx context.Context, req interface{}, endpoint domain.Endpoint, out interface{}) (int, error) {
+	// The client has no timeout of its own, so that a longer context deadline is honoured.
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.RequestTimeout())
+		defer cancel()
+	}
+
+	data, err := s.encoder.Encode(req)
 	if err != nil {
-		return nil, err
+		return 0, &Error{Endpoint: endpoint, Kind: ErrorKindValidation, Err: err}
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.config.URL(endpoint), bytes.NewBuffer(data))
+	if err != nil {
+		return 0, &Error{Endpoint: endpoint, Err: err}
+	}
+	httpReq.Header.Set("Content-Type", s.encoder.ContentType())
 
 	resp, err := s.client.Do(httpReq)
 	if err != nil {
-		return nil, err
+		return 0, newTransportError(endpoint, err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return resp.StatusCode, newTransportError(endpoint, err)
 	}
 
-	var result domain.PayTRResponse
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode != 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return resp.StatusCode, newStatusError(endpoint, resp.StatusCode, "", "", "", truncate(string(body), 200))
 	}
 
-	return &result, nil
+	if err := json.Unmarshal(body, out); err != nil {
+		return resp.StatusCode, &Error{Endpoint: endpoint, HTTPStatus: resp.StatusCode, Err: fmt.Errorf("error decoding response: %v", err)}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// isYes interprets PayTR's flag values such as "y", "Y", "yes", "1" and "true".
+func isYes(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "y", "yes", "1", "true":
+		return true
+	}
+	return false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
 }
@@ -0,0 +1,120 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// ReconcilePolicy controls how the outcome of a payment is recovered when the payment
+// request fails ambiguously, e.g. with a timeout, after PayTR may already have charged the card.
+// Instead of retrying the payment, the service polls MerchantStatusInquiry for the same
+// merchant_oid until PayTR reports a definitive outcome.
+//
+// Reconciliation outlives the caller's context: when the payment request failed because the
+// context's deadline expired, the outcome still has to be recovered. Polling is bounded by
+// MaxPolls and Timeout instead, and keeps the values of the caller's context.
+type ReconcilePolicy struct {
+	// MaxPolls is the number of status inquiries made before giving up.
+	// Zero disables reconciliation.
+	MaxPolls int
+
+	// Interval is the delay before each status inquiry.
+	Interval time.Duration
+
+	// Timeout bounds the whole reconciliation, status inquiries included.
+	// Zero leaves it bounded by MaxPolls only.
+	Timeout time.Duration
+}
+
+// DefaultReconcilePolicy polls for about half a minute and gives up after a minute.
+var DefaultReconcilePolicy = ReconcilePolicy{
+	MaxPolls: 10,
+	Interval: 3 * time.Second,
+	Timeout:  time.Minute,
+}
+
+// isAmbiguous reports whether err leaves it unknown if the request reached PayTR and was processed.
+func isAmbiguous(err error) bool {
+	var paytrErr *Error
+	if !errors.As(err, &paytrErr) {
+		return false
+	}
+	if errors.Is(paytrErr.Err, context.Canceled) {
+		return false
+	}
+	// A response that could not be decoded may still stand for a completed payment.
+	return paytrErr.Kind == ErrorKindRetryable || (paytrErr.Err != nil && paytrErr.HTTPStatus != 0)
+}
+
+// sendPayment records and sends a payment request and, when reconciliation is enabled and
+// the request fails ambiguously, resolves its outcome through status inquiries.
+func (s *service) sendPayment(ctx context.Context, req interface{}, common domain.CommonPaymentRequest, method string) (*domain.PayTRResponse, error) {
+	if err := s.recordAttempt(ctx, common, method); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.sendRequest(ctx, req, domain.EndpointPayment)
+	if err != nil && s.reconcile.MaxPolls > 0 && isAmbiguous(err) {
+		resp, err = s.reconcilePayment(ctx, common.MerchantOid, err)
+	}
+	s.recordOutcome(ctx, common, err)
+	return resp, err
+}
+
+// reconcilePayment polls the status of merchantOid. A successful payment is returned as a
+// PayTRResponse and a failed one as an *Error. If no definitive outcome is known after
+// MaxPolls inquiries or within the policy's Timeout, cause is returned. The caller's ctx is
+// usually done already, so only its values are kept.
+func (s *service) reconcilePayment(ctx context.Context, merchantOid string, cause error) (*domain.PayTRResponse, error) {
+	ctx = context.WithoutCancel(ctx)
+	if s.reconcile.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.reconcile.Timeout)
+		defer cancel()
+	}
+
+	for poll := 0; poll < s.reconcile.MaxPolls; poll++ {
+		timer := time.NewTimer(s.reconcile.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, cause
+		case <-timer.C:
+		}
+
+		status, err := s.MerchantStatusInquiryContext(ctx, domain.StatusInquiryRequest{MerchantOid: merchantOid})
+		if err != nil {
+			var paytrErr *Error
+			if errors.As(err, &paytrErr) && paytrErr.Kind == ErrorKindAuth {
+				return nil, cause
+			}
+			// The order may not be visible yet; ask again.
+			continue
+		}
+
+		switch status.Status {
+		case "success":
+			return &domain.PayTRResponse{
+				Status:  "success",
+				Message: "Payment confirmed by status inquiry",
+				Data: map[string]interface{}{
+					"merchant_oid":   merchantOid,
+					"payment_amount": status.PaymentAmount,
+					"payment_total":  status.PaymentTotal,
+					"currency":       status.Currency,
+					"reconciled":     true,
+				},
+			}, nil
+		case "failed":
+			paytrErr := newStatusError(domain.EndpointPayment, 0, status.Status, status.ErrNo, status.ErrMsg, "")
+			if paytrErr.Kind == ErrorKindUnknown {
+				paytrErr.Kind = ErrorKindDeclined
+			}
+			return nil, paytrErr
+		}
+	}
+	return nil, cause
+}
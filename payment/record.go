@@ -0,0 +1,263 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// ErrNoPaymentRepository is returned by operations that need a payment repository
+// when none was configured with SetPaymentRepository.
+var ErrNoPaymentRepository = errors.New("paytr: no payment repository configured")
+
+// recordAttempt stores a new payment in the created status before it is sent to PayTR.
+// A payment that was created before but never sent successfully, e.g. after a timeout,
+// may be attempted again; any other payment with the same merchant_oid is rejected.
+func (s *service) recordAttempt(ctx context.Context, req domain.CommonPaymentRequest, method string) error {
+	if s.payments == nil {
+		return nil
+	}
+
+	existing, err := s.payments.FindPayment(ctx, req.MerchantOid)
+	switch {
+	case err == nil:
+		if existing.Status != domain.PaymentStatusCreated {
+			return &Error{
+				Endpoint: domain.EndpointPayment,
+				Kind:     ErrorKindValidation,
+				Err:      fmt.Errorf("%w: payment %s is already %s", domain.ErrIllegalTransition, req.MerchantOid, existing.Status),
+			}
+		}
+		return nil
+	case !errors.Is(err, domain.ErrNotFound):
+		return &Error{Endpoint: domain.EndpointPayment, Err: fmt.Errorf("error recording payment: %v", err)}
+	}
+
+	payment := domain.NewPayment(req.MerchantOid, req.PaymentAmount, time.Now())
+	payment.UserID = req.Email
+	payment.Currency = req.Currency
+	payment.PaymentMethod = method
+	if err := s.payments.SavePayment(ctx, payment); err != nil {
+		return &Error{Endpoint: domain.EndpointPayment, Err: fmt.Errorf("error recording payment: %v", err)}
+	}
+	return nil
+}
+
+// recordOutcome moves a payment according to the result of its request. An accepted 3D Secure
+// payment awaits the customer's authentication, which is reported by a notification. Payments
+// that failed ambiguously stay created until their outcome is known.
+func (s *service) recordOutcome(ctx context.Context, req domain.CommonPaymentRequest, err error) {
+	switch {
+	case err == nil && req.NonThreeD == "1":
+		s.recordStatus(ctx, req.MerchantOid, domain.PaymentStatusSucceeded, "payment response")
+	case err == nil:
+		s.recordStatus(ctx, req.MerchantOid, domain.PaymentStatusAwaiting3DS, "payment response")
+	case !isAmbiguous(err):
+		s.recordStatus(ctx, req.MerchantOid, domain.PaymentStatusFailed, err.Error())
+	}
+}
+
+// recordStatus moves a recorded payment to status. Payments that were not recorded by
+// this service and moves the lifecycle does not allow are ignored: a status inquiry
+// still reports success for a refunded payment, for example.
+// The payment has already been processed by PayTR at this point, so a storage
+// failure must not turn its result into an error; it is dropped instead.
+func (s *service) recordStatus(ctx context.Context, merchantOid string, status domain.PaymentStatus, reason string) {
+	if s.payments == nil {
+		return
+	}
+	s.updatePayment(ctx, merchantOid, func(payment *domain.Payment) error {
+		if status == domain.PaymentStatusSucceeded && payment.Status.IsPaid() {
+			return nil
+		}
+		return payment.Transition(status, time.Now(), reason)
+	})
+}
+
+// maxUpdateAttempts bounds how often updatePayment reloads a payment that keeps
+// being changed concurrently.
+const maxUpdateAttempts = 5
+
+// updatePayment loads a recorded payment, applies update and stores it if it changed.
+// Saves are compare-and-swap on the payment's Version: if another update, e.g. a
+// notification arriving while a refund is recorded, stored the payment in between,
+// it is loaded again and update is applied to the fresh copy.
+func (s *service) updatePayment(ctx context.Context, merchantOid string, update func(*domain.Payment) error) error {
+	for attempt := 1; ; attempt++ {
+		payment, err := s.payments.FindPayment(ctx, merchantOid)
+		if err != nil {
+			return err
+		}
+
+		changes := len(payment.History)
+		if err := update(payment); err != nil {
+			return err
+		}
+		if len(payment.History) == changes {
+			return nil
+		}
+		err = s.payments.SavePayment(ctx, payment)
+		if !errors.Is(err, domain.ErrConflict) || attempt == maxUpdateAttempts {
+			return err
+		}
+	}
+}
+
+// checkRefundable rejects a refund of a recorded payment that was never charged, has
+// been refunded in full or was paid in another currency than the refund's.
+func (s *service) checkRefundable(ctx context.Context, req domain.RefundRequest) error {
+	if s.payments == nil {
+		return nil
+	}
+
+	merchantOid := req.MerchantOid
+	payment, err := s.payments.FindPayment(ctx, merchantOid)
+	if err != nil {
+		// Payments made before recording was enabled can still be refunded.
+		return nil
+	}
+	if !req.ReturnAmount.SameCurrency(payment.Amount) {
+		v := &validator{}
+		v.add("return_amount", "is in %s but %s was paid in %s", req.ReturnAmount.Currency, merchantOid, payment.Amount.Currency)
+		return validationError(domain.EndpointRefund, v.err())
+	}
+	if !payment.Status.CanTransition(domain.PaymentStatusRefunded) {
+		return &Error{
+			Endpoint: domain.EndpointRefund,
+			Kind:     ErrorKindValidation,
+			Err:      fmt.Errorf("%w: payment %s is %s and cannot be refunded", domain.ErrIllegalTransition, merchantOid, payment.Status),
+		}
+	}
+	return nil
+}
+
+// recordRefund adds a successful refund to a recorded payment.
+func (s *service) recordRefund(ctx context.Context, req domain.RefundRequest) {
+	if s.payments == nil {
+		return
+	}
+
+	reason := "refund"
+	if req.ReferenceNo != "" {
+		reason += " " + req.ReferenceNo
+	}
+	s.updatePayment(ctx, req.MerchantOid, func(payment *domain.Payment) error {
+		return payment.Refund(req.ReturnAmount, time.Now(), reason)
+	})
+}
+
+func (s *service) ApplyNotification(n domain.PaymentNotification) error {
+	return s.ApplyNotificationContext(context.Background(), n)
+}
+
+func (s *service) ApplyNotificationContext(ctx context.Context, n domain.PaymentNotification) error {
+	if err := VerifyNotification(s.config, n); err != nil {
+		return err
+	}
+	if s.payments == nil {
+		return ErrNoPaymentRepository
+	}
+
+	return s.updatePayment(ctx, n.MerchantOid, func(payment *domain.Payment) error {
+		switch n.Status {
+		case "success":
+			if payment.Status.IsPaid() {
+				return nil
+			}
+			return payment.Transition(domain.PaymentStatusSucceeded, time.Now(), "notification")
+		case "failed":
+			return payment.Transition(domain.PaymentStatusFailed, time.Now(), firstNonEmpty(n.FailedReasonMsg, "notification"))
+		}
+		return fmt.Errorf("unknown notification status %q", n.Status)
+	})
+}
+
+func (s *service) ExpirePayment(merchantOid string) error {
+	return s.ExpirePaymentContext(context.Background(), merchantOid)
+}
+
+func (s *service) ExpirePaymentContext(ctx context.Context, merchantOid string) error {
+	if s.payments == nil {
+		return ErrNoPaymentRepository
+	}
+	return s.updatePayment(ctx, merchantOid, func(payment *domain.Payment) error {
+		return payment.Transition(domain.PaymentStatusExpired, time.Now(), "expired")
+	})
+}
+
+// recordNewCard stores the card saved by a successful payment with store_card set.
+func (s *service) recordNewCard(ctx context.Context, req domain.NewCardPaymentRequest, resp *domain.PayTRResponse) {
+	if s.cards == nil || resp == nil {
+		return
+	}
+
+	utoken, _ := resp.Data["utoken"].(string)
+	ctoken, _ := resp.Data["ctoken"].(string)
+	if utoken == "" || ctoken == "" {
+		return
+	}
+
+	s.cards.SaveCard(ctx, &domain.SavedCard{
+		UserID:     req.Email,
+		UToken:     utoken,
+		CToken:     ctoken,
+		LastFour:   lastFour(req.CardNumber),
+		ExpiryDate: expiryDate(req.ExpiryMonth, req.ExpiryYear),
+		Month:      req.ExpiryMonth,
+		Year:       req.ExpiryYear,
+		OwnerName:  req.CardOwner,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// recordCards stores the cards listed by PayTR for utoken, keeping the UserID and
+// CreatedAt of cards that were recorded before, and forgets recorded cards that
+// PayTR no longer lists.
+func (s *service) recordCards(ctx context.Context, utoken string, cards []domain.SavedCard) {
+	if s.cards == nil {
+		return
+	}
+
+	existing, err := s.cards.ListCards(ctx, utoken)
+	if err != nil {
+		return
+	}
+	known := make(map[string]domain.SavedCard, len(existing))
+	for _, card := range existing {
+		known[card.CToken] = card
+	}
+
+	now := time.Now()
+	for i := range cards {
+		card := cards[i]
+		if previous, ok := known[card.CToken]; ok {
+			card.ID = previous.ID
+			card.UserID = previous.UserID
+			card.CreatedAt = previous.CreatedAt
+			delete(known, card.CToken)
+		} else {
+			card.CreatedAt = now
+		}
+		s.cards.SaveCard(ctx, &card)
+	}
+	for ctoken := range known {
+		s.cards.DeleteCard(ctx, utoken, ctoken)
+	}
+}
+
+// recordDeletedCard forgets a card deleted at PayTR.
+func (s *service) recordDeletedCard(ctx context.Context, utoken, ctoken string) {
+	if s.cards != nil {
+		s.cards.DeleteCard(ctx, utoken, ctoken)
+	}
+}
+
+func lastFour(number string) string {
+	if len(number) < 4 {
+		return number
+	}
+	return number[len(number)-4:]
+}
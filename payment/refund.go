@@ -0,0 +1,72 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// ErrRefundExceedsRemaining is wrapped by the error RefundPayment returns when the amount
+// exceeds what can still be refunded.
+var ErrRefundExceedsRemaining = errors.New("refund exceeds the refundable amount")
+
+func (s *service) GetRefunds(merchantOid string) (*domain.RefundSummary, error) {
+	return s.GetRefundsContext(context.Background(), merchantOid)
+}
+
+func (s *service) GetRefundsContext(ctx context.Context, merchantOid string) (*domain.RefundSummary, error) {
+	status, err := s.MerchantStatusInquiryContext(ctx, domain.StatusInquiryRequest{MerchantOid: merchantOid})
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := status.RefundSummary(merchantOid)
+	if err != nil {
+		return nil, &Error{Endpoint: domain.EndpointStatusInquiry, Err: fmt.Errorf("error decoding response: %v", err)}
+	}
+	return &summary, nil
+}
+
+// checkRemaining rejects a refund larger than the remaining refundable amount of the
+// payment unless the refund check is disabled.
+func (s *service) checkRemaining(ctx context.Context, req domain.RefundRequest) error {
+	if s.skipRefundCheck {
+		return nil
+	}
+
+	summary, err := s.GetRefundsContext(ctx, req.MerchantOid)
+	if err != nil {
+		return err
+	}
+	if !req.ReturnAmount.SameCurrency(summary.Remaining) {
+		v := &validator{}
+		v.add("return_amount", "is in %s but %s was paid in %s", req.ReturnAmount.Currency, req.MerchantOid, summary.Remaining.Currency)
+		return validationError(domain.EndpointRefund, v.err())
+	}
+	if req.ReturnAmount.Cmp(summary.Remaining) > 0 {
+		return &Error{
+			Endpoint: domain.EndpointRefund,
+			Kind:     ErrorKindValidation,
+			Err: fmt.Errorf("%w: %s requested, %s of %s remaining for %s",
+				ErrRefundExceedsRemaining, req.ReturnAmount, summary.Remaining, summary.Paid, req.MerchantOid),
+		}
+	}
+	return nil
+}
+
+// newReferenceNo returns a unique reference number for a refund, made of letters and
+// digits as PayTR requires, e.g. "RM2K8Z1QX0A3F9C1B7E".
+func newReferenceNo() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("paytr: %v", err))
+	}
+	return "R" + strings.ToUpper(strconv.FormatInt(time.Now().UnixNano(), 36)+hex.EncodeToString(b))
+}
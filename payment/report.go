@@ -0,0 +1,138 @@
+package payment
+
+import (
+	"context"
+	"iter"
+	"sort"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// ReportPolicy controls how GetTransactionDetailsRange splits a period into the
+// windows PayTR's transaction report accepts.
+type ReportPolicy struct {
+	// Window is the widest period queried with a single request.
+	Window time.Duration
+
+	// Workers is the number of windows queried concurrently.
+	Workers int
+}
+
+// DefaultReportPolicy queries one day per request, four days at a time.
+var DefaultReportPolicy = ReportPolicy{
+	Window:  24 * time.Hour,
+	Workers: 4,
+}
+
+// reportWindow is a period [start, end) queried with a single request.
+type reportWindow struct {
+	start, end time.Time
+}
+
+// request returns the report request for the window. PayTR's end date is inclusive
+// and has a precision of one second.
+func (w reportWindow) request() domain.TransactionDetailsRequest {
+	return domain.TransactionDetailsRequest{
+		StartDate: w.start.In(domain.PayTRLocation).Format(domain.ReportTimeFormat),
+		EndDate:   w.end.Add(-time.Second).In(domain.PayTRLocation).Format(domain.ReportTimeFormat),
+	}
+}
+
+// splitWindows splits [from, to) into consecutive windows of at most size.
+func splitWindows(from, to time.Time, size time.Duration) []reportWindow {
+	if size <= 0 {
+		size = DefaultReportPolicy.Window
+	}
+	var windows []reportWindow
+	for start := from; start.Before(to); start = start.Add(size) {
+		end := start.Add(size)
+		if end.After(to) {
+			end = to
+		}
+		windows = append(windows, reportWindow{start, end})
+	}
+	return windows
+}
+
+type windowResult struct {
+	transactions []domain.Transaction
+	err          error
+}
+
+func (s *service) GetTransactionDetailsRange(from, to time.Time) iter.Seq2[domain.Transaction, error] {
+	return s.GetTransactionDetailsRangeContext(context.Background(), from, to)
+}
+
+func (s *service) GetTransactionDetailsRangeContext(ctx context.Context, from, to time.Time) iter.Seq2[domain.Transaction, error] {
+	return func(yield func(domain.Transaction, error) bool) {
+		windows := splitWindows(from, to, s.report.Window)
+		if len(windows) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// Every window has its own buffered channel so that workers never block and
+		// results can be yielded in order while later windows are still being queried.
+		results := make([]chan windowResult, len(windows))
+		for i := range results {
+			results[i] = make(chan windowResult, 1)
+		}
+
+		jobs := make(chan int)
+		go func() {
+			defer close(jobs)
+			for i := range windows {
+				select {
+				case jobs <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		workers := s.report.Workers
+		if workers < 1 {
+			workers = 1
+		}
+		for w := 0; w < workers && w < len(windows); w++ {
+			go func() {
+				for i := range jobs {
+					resp, err := s.GetTransactionDetailsContext(ctx, windows[i].request())
+					if err != nil {
+						results[i] <- windowResult{err: err}
+						continue
+					}
+					results[i] <- windowResult{transactions: resp.Transactions}
+				}
+			}()
+		}
+
+		// Windows do not overlap: each one ends a second before the next starts, and PayTR's
+		// end date is inclusive. Every transaction is therefore reported by one window only.
+		for i := range windows {
+			var result windowResult
+			select {
+			case result = <-results[i]:
+			case <-ctx.Done():
+				yield(domain.Transaction{}, ctx.Err())
+				return
+			}
+			if result.err != nil {
+				yield(domain.Transaction{}, result.err)
+				return
+			}
+
+			sort.SliceStable(result.transactions, func(a, b int) bool {
+				return result.transactions[a].IslemTarihi < result.transactions[b].IslemTarihi
+			})
+			for _, t := range result.transactions {
+				if !yield(t, nil) {
+					return
+				}
+			}
+		}
+	}
+}
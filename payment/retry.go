@@ -0,0 +1,110 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// RetryPolicy controls how failed requests to read-only endpoints are retried.
+// Requests that move money, such as payments and refunds, are never retried automatically.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+
+	// Multiplier is applied to the delay after every attempt.
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction, e.g. 0.2 for ±20%.
+	Jitter float64
+
+	// RetryOn decides whether a failed attempt is retried. By default errors
+	// classified as ErrorKindRetryable are retried.
+	RetryOn func(err *Error) bool
+}
+
+// DefaultRetryPolicy is the retry policy used by NewService.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// NoRetry disables automatic retries.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// idempotentEndpoints lists the endpoints that only read data and can be retried safely.
+var idempotentEndpoints = map[domain.Endpoint]bool{
+	domain.EndpointStatusInquiry:      true,
+	domain.EndpointTransactionDetails: true,
+	domain.EndpointCardList:           true,
+	domain.EndpointBinDetail:          true,
+	domain.EndpointInstallmentRates:   true,
+	domain.EndpointReturnedTransfers:  true,
+}
+
+// shouldRetry reports whether err, returned by the given attempt (starting at 1), is retried.
+func (p RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+
+	var paytrErr *Error
+	if !errors.As(err, &paytrErr) {
+		return false
+	}
+	if p.RetryOn != nil {
+		return p.RetryOn(paytrErr)
+	}
+	return paytrErr.Retryable()
+}
+
+// backoff returns the delay before the retry that follows the given attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay *= 1 - p.Jitter + 2*p.Jitter*rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// withRetry calls fn until it succeeds, the policy gives up or ctx is done.
+// Only requests to idempotent endpoints are retried.
+func (s *service) withRetry(ctx context.Context, endpoint domain.Endpoint, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !idempotentEndpoints[endpoint] || !s.retry.shouldRetry(attempt, err) {
+			return err
+		}
+
+		timer := time.NewTimer(s.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
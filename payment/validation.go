@@ -0,0 +1,374 @@
+package payment
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streamerd/paytr-go/card"
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every problem found in a request before it is sent to PayTR.
+// Service methods return it wrapped in an *Error of kind ErrorKindValidation, so it can
+// be retrieved with errors.As.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Has reports whether the given field has a problem.
+func (e *ValidationError) Has(field string) bool {
+	for _, fe := range e.Errors {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Field limits and accepted values of PayTR requests.
+const (
+	MaxMerchantOidLength = 64
+	MaxEmailLength       = 100
+	MaxUserNameLength    = 60
+	MaxUserAddressLength = 400
+	MaxUserPhoneLength   = 20
+	MaxInstallmentCount  = 12
+)
+
+var (
+	validCurrencies  = map[string]bool{"TL": true, "TRY": true, "EUR": true, "USD": true, "GBP": true, "RUB": true}
+	validClientLangs = map[string]bool{"": true, "tr": true, "en": true}
+)
+
+// validator collects field errors.
+type validator struct {
+	errs []FieldError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) merchantOid(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	if len(value) > MaxMerchantOidLength {
+		v.add(field, "must be at most %d characters", MaxMerchantOidLength)
+	}
+	if !isAlphanumeric(value) {
+		v.add(field, "must contain only letters and digits")
+	}
+}
+
+func (v *validator) email(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	if len(value) > MaxEmailLength {
+		v.add(field, "must be at most %d characters", MaxEmailLength)
+	}
+	if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+		v.add(field, "is not a valid email address")
+	}
+}
+
+func (v *validator) ip(field, value string) {
+	if v.required(field, value) && net.ParseIP(value) == nil {
+		v.add(field, "is not a valid IP address")
+	}
+}
+
+func (v *validator) phone(field, value string) {
+	if value == "" {
+		return
+	}
+	if len(value) > MaxUserPhoneLength {
+		v.add(field, "must be at most %d characters", MaxUserPhoneLength)
+	}
+	digits := strings.TrimPrefix(value, "+")
+	digits = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(digits)
+	if digits == "" || !isDigits(digits) {
+		v.add(field, "is not a valid phone number")
+	}
+}
+
+func (v *validator) maxLength(field, value string, max int) {
+	if len([]rune(value)) > max {
+		v.add(field, "must be at most %d characters", max)
+	}
+}
+
+func (v *validator) url(field, value string) {
+	if value == "" {
+		return
+	}
+	if u, err := url.Parse(value); err != nil || !u.IsAbs() || u.Host == "" {
+		v.add(field, "must be an absolute URL")
+	}
+}
+
+func (v *validator) flag(field, value string) {
+	if value != "" && value != "0" && value != "1" {
+		v.add(field, "must be 0 or 1")
+	}
+}
+
+func (v *validator) positive(field string, amount domain.Money) {
+	if !amount.IsPositive() {
+		v.add(field, "must be greater than zero")
+	}
+}
+
+func (v *validator) currency(field, value string) {
+	if v.required(field, value) && !validCurrencies[value] {
+		v.add(field, "must be one of TL, TRY, EUR, USD, GBP, RUB")
+	}
+}
+
+// amountCurrency checks that amount is in currency, the currency sent with the request.
+// Amounts without a currency take the request's.
+func (v *validator) amountCurrency(field string, amount domain.Money, currency string) {
+	if !amount.SameCurrency(domain.NewMoney(0, currency)) {
+		v.add(field, "currency %s does not match currency %s", amount.Currency, currency)
+	}
+}
+
+func (v *validator) clientLang(field, value string) {
+	if !validClientLangs[value] {
+		v.add(field, "must be tr or en")
+	}
+}
+
+func (v *validator) installmentCount(field, value string) {
+	if value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > MaxInstallmentCount {
+		v.add(field, "must be between 0 and %d", MaxInstallmentCount)
+	}
+}
+
+func (v *validator) digits(field, value string, min, max int) {
+	if !v.required(field, value) {
+		return
+	}
+	if !isDigits(value) || len(value) < min || len(value) > max {
+		if min == max {
+			v.add(field, "must be %d digits", min)
+		} else {
+			v.add(field, "must be %d to %d digits", min, max)
+		}
+	}
+}
+
+func (v *validator) common(req domain.CommonPaymentRequest) {
+	v.merchantOid("merchant_oid", req.MerchantOid)
+	v.email("email", req.Email)
+	v.ip("user_ip", req.UserIP)
+	v.positive("payment_amount", req.PaymentAmount)
+	v.currency("currency", req.Currency)
+	v.amountCurrency("payment_amount", req.PaymentAmount, req.Currency)
+	if req.PaymentType != "card" {
+		v.add("payment_type", "must be card")
+	}
+	v.installmentCount("installment_count", req.InstallmentCount)
+	v.flag("non_3d", req.NonThreeD)
+	v.flag("test_mode", req.TestMode)
+	v.flag("debug_on", req.DebugOn)
+	v.clientLang("client_lang", req.ClientLang)
+	v.maxLength("user_name", req.UserName, MaxUserNameLength)
+	v.maxLength("user_address", req.UserAddress, MaxUserAddressLength)
+	v.phone("user_phone", req.UserPhone)
+	v.url("merchant_ok_url", req.MerchantOkURL)
+	v.url("merchant_fail_url", req.MerchantFailURL)
+	v.basket("user_basket", req.UserBasket, req.PaymentAmount)
+}
+
+func (v *validator) basket(field string, basket domain.Basket, amount domain.Money) {
+	if err := basket.Validate(amount); err != nil {
+		v.add(field, "%v", err)
+	}
+}
+
+func (v *validator) card(owner, number, month, year, cvv string) {
+	v.required("cc_owner", owner)
+	if v.required("card_number", number) && !card.Luhn(number) {
+		v.add("card_number", "is not a valid card number")
+	}
+
+	monthOK, yearOK := v.required("expiry_month", month), v.required("expiry_year", year)
+	if monthOK {
+		if m, err := strconv.Atoi(month); err != nil || m < 1 || m > 12 {
+			v.add("expiry_month", "must be between 1 and 12")
+			monthOK = false
+		}
+	}
+	if yearOK && (!isDigits(year) || (len(year) != 2 && len(year) != 4)) {
+		v.add("expiry_year", "must be 2 or 4 digits")
+		yearOK = false
+	}
+	if monthOK && yearOK && card.CheckExpiry(month, year, time.Now()) != nil {
+		v.add("expiry_year", "card has expired")
+	}
+
+	if v.required("cvv", cvv) {
+		brand := card.DetectBrand(number)
+		if card.CheckCVV(cvv, brand) != nil {
+			v.add("cvv", "must be %d digits", card.CVVLength(brand))
+		}
+	}
+}
+
+// ValidateNewCardPayment checks a new card payment request before it is sent.
+func ValidateNewCardPayment(req domain.NewCardPaymentRequest) error {
+	v := &validator{}
+	v.common(req.CommonPaymentRequest)
+	v.card(req.CardOwner, req.CardNumber, req.ExpiryMonth, req.ExpiryYear, req.CVV)
+	v.flag("store_card", req.StoreCard)
+	return v.err()
+}
+
+// ValidateIFrameToken checks an iFrame token request before it is sent with the same
+// rules as the Direct API requests, and that the basket matches the payment amount.
+func ValidateIFrameToken(req domain.IFrameTokenRequest) error {
+	v := &validator{}
+	v.merchantOid("merchant_oid", req.MerchantOid)
+	v.email("email", req.Email)
+	v.ip("user_ip", req.UserIP)
+	v.positive("payment_amount", req.PaymentAmount)
+	v.currency("currency", req.Currency)
+	v.amountCurrency("payment_amount", req.PaymentAmount, req.Currency)
+	v.flag("test_mode", req.TestMode)
+	v.flag("no_installment", req.NoInstallment)
+	v.installmentCount("max_installment", req.MaxInstallment)
+	v.basket("user_basket", req.UserBasket, req.PaymentAmount)
+	return v.err()
+}
+
+// ValidateSavedCardPayment checks a saved card or recurring payment request before it is sent.
+func ValidateSavedCardPayment(req domain.SavedCardPaymentRequest) error {
+	v := &validator{}
+	v.common(req.CommonPaymentRequest)
+	v.required("utoken", req.UToken)
+	v.required("ctoken", req.CToken)
+	if req.CVV != "" {
+		v.digits("cvv", req.CVV, 3, 4)
+	}
+	v.flag("recurring_payment", req.RecurringPayment)
+	return v.err()
+}
+
+// ValidateAddNewCard checks a request to save a new card before it is sent.
+func ValidateAddNewCard(req domain.AddNewCardRequest) error {
+	v := &validator{}
+	v.merchantOid("merchant_oid", req.MerchantOid)
+	v.email("email", req.Email)
+	v.ip("user_ip", req.UserIP)
+	v.phone("user_phone", req.UserPhone)
+	v.url("merchant_ok_url", req.MerchantOkURL)
+	v.url("merchant_fail_url", req.MerchantFailURL)
+	v.card(req.CardOwner, req.CardNumber, req.ExpiryMonth, req.ExpiryYear, req.CVV)
+	return v.err()
+}
+
+// ValidateRefund checks a refund request before it is sent.
+func ValidateRefund(req domain.RefundRequest) error {
+	v := &validator{}
+	v.merchantOid("merchant_oid", req.MerchantOid)
+	v.positive("return_amount", req.ReturnAmount)
+	if req.ReturnAmount.Currency != "" && !validCurrencies[req.ReturnAmount.Currency] {
+		v.add("return_amount", "currency must be one of TL, TRY, EUR, USD, GBP, RUB")
+	}
+	if req.ReferenceNo != "" {
+		if len(req.ReferenceNo) > MaxMerchantOidLength || !isAlphanumeric(req.ReferenceNo) {
+			v.add("reference_no", "must be at most %d letters and digits", MaxMerchantOidLength)
+		}
+	}
+	return v.err()
+}
+
+// ValidatePlatformTransfer checks the fields of a platform transfer request.
+// The IBAN is expected to be normalized with NormalizeIBAN.
+func ValidatePlatformTransfer(req domain.PlatformTransferRequest) error {
+	v := &validator{}
+	v.merchantOid("merchant_oid", req.MerchantOid)
+	v.merchantOid("trans_id", req.TransID)
+	v.positive("submerchant_amount", req.SubmerchantAmount)
+	v.positive("total_amount", req.TotalAmount)
+	if !req.SubmerchantAmount.SameCurrency(req.TotalAmount) {
+		v.add("submerchant_amount", "currency %s does not match total_amount currency %s", req.SubmerchantAmount.Currency, req.TotalAmount.Currency)
+	} else if req.SubmerchantAmount.Cmp(req.TotalAmount) > 0 {
+		v.add("submerchant_amount", "must not exceed total_amount")
+	}
+	v.required("transfer_name", req.TransferName)
+	if v.required("transfer_iban", req.TransferIBAN) && !validIBAN(req.TransferIBAN) {
+		v.add("transfer_iban", "is not a valid IBAN")
+	}
+	return v.err()
+}
+
+// validationError wraps the result of a Validate function for the given endpoint.
+func validationError(endpoint domain.Endpoint, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Endpoint: endpoint, Kind: ErrorKindValidation, Err: err}
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
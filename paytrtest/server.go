@@ -0,0 +1,945 @@
+// Package paytrtest provides a local stand-in for the PayTR API, for use in integration tests.
+//
+// The Server implements the endpoints called by payment.Service, verifies the paytr_token
+// of every request with the merchant's credentials and keeps orders, saved cards and
+// refunds in memory. Payment notifications are posted to NotifyURL when it is set.
+//
+// Example usage:
+//
+//	srv := paytrtest.NewServer(config.PayTRConfig{
+//		MerchantID:   "test_merchant",
+//		MerchantKey:  "test_key",
+//		MerchantSalt: "test_salt",
+//	})
+//	defer srv.Close()
+//
+//	svc := payment.NewService(srv.Config())
+package paytrtest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamerd/paytr-go/config"
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// Order is a payment recorded by the server.
+type Order struct {
+	MerchantOid      string
+	Email            string
+	Amount           domain.Money
+	PaymentType      string
+	InstallmentCount string
+	TestMode         string
+	Status           string
+	FailedReason     string
+	CardNumber       string
+	CardBrand        string
+	Refunds          []Refund
+	CreatedAt        time.Time
+}
+
+// Refunded returns the sum of all refunds of the order.
+func (o Order) Refunded() domain.Money {
+	total := domain.NewMoney(0, o.Amount.Currency)
+	for _, r := range o.Refunds {
+		total = total.Add(r.Amount)
+	}
+	return total
+}
+
+// Refund is a refund recorded against an order.
+type Refund struct {
+	Amount      domain.Money
+	ReferenceNo string
+	CreatedAt   time.Time
+}
+
+// Transfer is a platform transfer of a submerchant's share of an order.
+type Transfer struct {
+	TransID           string
+	MerchantOid       string
+	SubmerchantAmount domain.Money
+	TotalAmount       domain.Money
+	TransferName      string
+	TransferIBAN      string
+	CreatedAt         time.Time
+
+	// ReturnedAt is set by ReturnTransfer when the receiving bank sends the transfer back.
+	ReturnedAt time.Time
+}
+
+// Card is a card saved for a user token.
+type Card struct {
+	UToken     string
+	CToken     string
+	CardNumber string
+	Owner      string
+	Month      string
+	Year       string
+	Brand      string
+	Type       string
+	Bank       string
+}
+
+// Server is an in-memory PayTR API backed by an httptest.Server.
+type Server struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:1234.
+	URL string
+
+	// NotifyURL receives payment notifications when set. Notifications are
+	// delivered before the payment response is returned.
+	NotifyURL string
+
+	// TransferNotifyURL receives platform transfer results when set. Results are
+	// delivered before the transfer response is returned.
+	TransferNotifyURL string
+
+	// Now returns the time used for new orders and refunds. It defaults to time.Now.
+	Now func() time.Time
+
+	config    config.PayTRConfig
+	server    *httptest.Server
+	client    *http.Client
+	mu        sync.Mutex
+	orders    map[string]*Order
+	cards     map[string][]Card
+	declined  map[string]string
+	bins      map[string]map[string]interface{}
+	rates     domain.InstallmentRates
+	transfers map[string]*Transfer
+}
+
+// NewServer starts a server that accepts requests signed with the credentials in config.
+// The caller should call Close when finished.
+func NewServer(config config.PayTRConfig) *Server {
+	s := &Server{
+		Now:       time.Now,
+		config:    config,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		orders:    map[string]*Order{},
+		cards:     map[string][]Card{},
+		declined:  map[string]string{},
+		bins:      defaultBins(),
+		rates:     defaultInstallmentRates(),
+		transfers: map[string]*Transfer{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(string(domain.EndpointPayment), s.handlePayment)
+	mux.HandleFunc(string(domain.EndpointRefund), s.handleRefund)
+	mux.HandleFunc(string(domain.EndpointStatusInquiry), s.handleStatusInquiry)
+	mux.HandleFunc(string(domain.EndpointTransactionDetails), s.handleTransactionDetails)
+	mux.HandleFunc(string(domain.EndpointCardList), s.handleCardList)
+	mux.HandleFunc(string(domain.EndpointCardDelete), s.handleCardDelete)
+	mux.HandleFunc(string(domain.EndpointBinDetail), s.handleBinDetail)
+	mux.HandleFunc(string(domain.EndpointIFrameToken), s.handleIFrameToken)
+	mux.HandleFunc(string(domain.EndpointInstallmentRates), s.handleInstallmentRates)
+	mux.HandleFunc(string(domain.EndpointPlatformTransfer), s.handlePlatformTransfer)
+	mux.HandleFunc(string(domain.EndpointReturnedTransfers), s.handleReturnedTransfers)
+
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Config returns the merchant configuration with BaseURL pointing at the server.
+func (s *Server) Config() config.PayTRConfig {
+	cfg := s.config
+	cfg.BaseURL = s.URL
+	return cfg
+}
+
+// Decline makes payments with the given card number fail with reason.
+func (s *Server) Decline(cardNumber, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.declined[cardNumber] = reason
+}
+
+// AddBin registers the details returned for a BIN lookup.
+func (s *Server) AddBin(bin string, details map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bins[bin] = details
+}
+
+// SetInstallmentRates replaces the rates returned by the installment-rates endpoint.
+func (s *Server) SetInstallmentRates(rates domain.InstallmentRates) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rates = rates
+}
+
+// Order returns a copy of the order with the given merchant_oid.
+func (s *Server) Order(merchantOid string) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[merchantOid]
+	if !ok {
+		return Order{}, false
+	}
+	cp := *o
+	cp.Refunds = append([]Refund(nil), o.Refunds...)
+	return cp, true
+}
+
+// Cards returns the cards saved for the user token.
+func (s *Server) Cards(utoken string) []Card {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Card(nil), s.cards[utoken]...)
+}
+
+// Transfer returns a copy of the transfer with the given trans_id.
+func (s *Server) Transfer(transID string) (Transfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[transID]
+	if !ok {
+		return Transfer{}, false
+	}
+	return *t, true
+}
+
+// ReturnTransfer marks a transfer as sent back by the receiving bank, so that it is
+// listed by the returned transfers endpoint. It reports whether the transfer exists.
+func (s *Server) ReturnTransfer(transID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[transID]
+	if ok {
+		t.ReturnedAt = s.Now()
+	}
+	return ok
+}
+
+// HANDLERS
+
+func (s *Server) handlePayment(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+
+	hashStr := p.Get("merchant_id") + p.Get("user_ip") + p.Get("merchant_oid") + p.Get("email") +
+		p.Get("payment_amount") + p.Get("payment_type") + p.Get("installment_count") +
+		p.Get("currency") + p.Get("test_mode") + p.Get("non_3d")
+	if !s.verify(w, p, hashStr) {
+		return
+	}
+
+	amount, err := domain.ParseMoney(p.Get("payment_amount"), p.Get("currency"))
+	if err != nil || !amount.IsPositive() {
+		writeFailed(w, "invalid payment_amount")
+		return
+	}
+	if p.Get("merchant_oid") == "" {
+		writeFailed(w, "merchant_oid is required")
+		return
+	}
+
+	s.mu.Lock()
+	if _, exists := s.orders[p.Get("merchant_oid")]; exists {
+		s.mu.Unlock()
+		writeFailed(w, "merchant_oid has already been used")
+		return
+	}
+
+	order := &Order{
+		MerchantOid:      p.Get("merchant_oid"),
+		Email:            p.Get("email"),
+		Amount:           amount,
+		PaymentType:      p.Get("payment_type"),
+		InstallmentCount: p.Get("installment_count"),
+		TestMode:         p.Get("test_mode"),
+		Status:           "success",
+		CreatedAt:        s.Now(),
+	}
+
+	data := map[string]interface{}{"merchant_oid": order.MerchantOid}
+
+	if utoken, ctoken := p.Get("utoken"), p.Get("ctoken"); utoken != "" && ctoken != "" && p.Get("card_number") == "" {
+		card, found := s.findCard(utoken, ctoken)
+		if !found {
+			s.mu.Unlock()
+			writeFailed(w, "card not found")
+			return
+		}
+		order.CardNumber = card.CardNumber
+		order.CardBrand = card.Brand
+	} else {
+		order.CardNumber = p.Get("card_number")
+		order.CardBrand = brandOf(order.CardNumber)
+
+		if _, declined := s.declined[order.CardNumber]; !declined && p.Get("store_card") == "1" {
+			if utoken == "" {
+				utoken = randomToken()
+			}
+			card := Card{
+				UToken:     utoken,
+				CToken:     randomToken(),
+				CardNumber: order.CardNumber,
+				Owner:      p.Get("cc_owner"),
+				Month:      p.Get("expiry_month"),
+				Year:       p.Get("expiry_year"),
+				Brand:      order.CardBrand,
+				Type:       "credit",
+				Bank:       "Test Bank",
+			}
+			s.cards[utoken] = append(s.cards[utoken], card)
+			data["utoken"] = card.UToken
+			data["ctoken"] = card.CToken
+		}
+	}
+
+	if reason, declined := s.declined[order.CardNumber]; declined {
+		order.Status = "failed"
+		order.FailedReason = reason
+	}
+	s.orders[order.MerchantOid] = order
+	snapshot := *order
+	s.mu.Unlock()
+
+	s.notify(snapshot)
+
+	if snapshot.Status != "success" {
+		writeJSON(w, map[string]interface{}{"status": "failed", "err_no": "0", "message": snapshot.FailedReason, "data": data})
+		return
+	}
+	writeJSON(w, map[string]interface{}{"status": "success", "message": "Payment successful", "data": data})
+}
+
+func (s *Server) handleRefund(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+	if !s.verify(w, p, p.Get("merchant_id")+p.Get("merchant_oid")+p.Get("return_amount")) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, found := s.orders[p.Get("merchant_oid")]
+	if !found {
+		writeFailed(w, "order not found")
+		return
+	}
+	if order.Status != "success" {
+		writeFailed(w, "order has not been paid")
+		return
+	}
+
+	amount, err := domain.ParseMoney(p.Get("return_amount"), order.Amount.Currency)
+	if err != nil || !amount.IsPositive() {
+		writeFailed(w, "invalid return_amount")
+		return
+	}
+	if order.Refunded().Add(amount).Cmp(order.Amount) > 0 {
+		writeFailed(w, "return_amount exceeds the refundable amount")
+		return
+	}
+
+	order.Refunds = append(order.Refunds, Refund{
+		Amount:      amount,
+		ReferenceNo: p.Get("reference_no"),
+		CreatedAt:   s.Now(),
+	})
+
+	writeJSON(w, map[string]interface{}{
+		"status":  "success",
+		"message": "Refund successful",
+		"data": map[string]interface{}{
+			"merchant_oid":  order.MerchantOid,
+			"return_amount": amount.String(),
+			"reference_no":  p.Get("reference_no"),
+		},
+	})
+}
+
+func (s *Server) handleStatusInquiry(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+	if !s.verify(w, p, p.Get("merchant_id")+p.Get("merchant_oid")) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, found := s.orders[p.Get("merchant_oid")]
+	if !found {
+		writeFailed(w, "order not found")
+		return
+	}
+
+	data := map[string]interface{}{
+		"status":         order.Status,
+		"payment_amount": order.Amount.String(),
+		"payment_total":  order.Amount.String(),
+		"payment_date":   order.CreatedAt.Format("2006-01-02 15:04:05"),
+		"currency":       order.Amount.Currency,
+		"taksit":         order.InstallmentCount,
+		"kart_marka":     order.CardBrand,
+		"masked_pan":     maskPan(order.CardNumber),
+		"odeme_tipi":     order.PaymentType,
+		"test_mode":      order.TestMode,
+	}
+	if order.Status != "success" {
+		data["err_msg"] = order.FailedReason
+	}
+	if len(order.Refunds) > 0 {
+		returns := make([]map[string]interface{}, len(order.Refunds))
+		for i, refund := range order.Refunds {
+			at := refund.CreatedAt.In(domain.PayTRLocation).Format(domain.ReportTimeFormat)
+			returns[i] = map[string]interface{}{
+				"return_amount":  refund.Amount.String(),
+				"return_date":    at,
+				"return_type":    "iade",
+				"date_completed": at,
+				"return_status":  "success",
+				"reference_no":   refund.ReferenceNo,
+				"return_source":  "api",
+			}
+		}
+		data["returns"] = returns
+	}
+
+	writeJSON(w, map[string]interface{}{"status": "success", "data": data})
+}
+
+func (s *Server) handleTransactionDetails(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+	if !s.verify(w, p, p.Get("merchant_id")+p.Get("start_date")+p.Get("end_date")) {
+		return
+	}
+
+	start, errStart := parseDate(p.Get("start_date"), false)
+	end, errEnd := parseDate(p.Get("end_date"), true)
+	if errStart != nil || errEnd != nil {
+		writeError(w, "invalid start_date or end_date")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transactions []domain.Transaction
+	for _, order := range s.orders {
+		if order.Status != "success" {
+			continue
+		}
+		if inRange(order.CreatedAt, start, end) {
+			transactions = append(transactions, transaction(order, "Satis", order.Amount, order.CreatedAt))
+		}
+		for _, refund := range order.Refunds {
+			if inRange(refund.CreatedAt, start, end) {
+				transactions = append(transactions, transaction(order, "Iade", refund.Amount, refund.CreatedAt))
+			}
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].IslemTarihi < transactions[j].IslemTarihi
+	})
+	if len(transactions) == 0 {
+		// PayTR answers "failed" for a period without transactions.
+		writeFailed(w, "no transactions in the period")
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"status":       "success",
+			"transactions": transactions,
+		},
+	})
+}
+
+func (s *Server) handleCardList(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+	if !s.verify(w, p, p.Get("utoken")) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cards := []map[string]interface{}{}
+	for _, card := range s.cards[p.Get("utoken")] {
+		cards = append(cards, map[string]interface{}{
+			"ctoken":      card.CToken,
+			"last_4":      lastFour(card.CardNumber),
+			"month":       card.Month,
+			"year":        card.Year,
+			"c_bank":      card.Bank,
+			"c_name":      card.Owner,
+			"c_brand":     card.Brand,
+			"c_type":      card.Type,
+			"require_cvv": "0",
+			"schema":      strings.ToUpper(card.Brand),
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{"status": "success", "data": map[string]interface{}{"cards": cards}})
+}
+
+func (s *Server) handleCardDelete(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+	if !s.verify(w, p, p.Get("utoken")+p.Get("ctoken")) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cards := s.cards[p.Get("utoken")]
+	for i, card := range cards {
+		if card.CToken == p.Get("ctoken") {
+			s.cards[p.Get("utoken")] = append(cards[:i:i], cards[i+1:]...)
+			writeJSON(w, map[string]interface{}{"status": "success", "message": "Card deleted"})
+			return
+		}
+	}
+	writeFailed(w, "card not found")
+}
+
+func (s *Server) handleBinDetail(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+	if !s.verify(w, p, p.Get("bin_number")+p.Get("merchant_id")) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bin := p.Get("bin_number")
+	for length := len(bin); length >= 6; length-- {
+		if details, found := s.bins[bin[:length]]; found {
+			writeJSON(w, map[string]interface{}{"status": "success", "data": details})
+			return
+		}
+	}
+	writeFailed(w, "bin not found")
+}
+
+func (s *Server) handleIFrameToken(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+
+	hashStr := p.Get("merchant_id") + p.Get("user_ip") + p.Get("merchant_oid") + p.Get("email") +
+		p.Get("payment_amount") + p.Get("user_basket") + p.Get("no_installment") +
+		p.Get("max_installment") + p.Get("currency") + p.Get("test_mode")
+	if !s.verify(w, p, hashStr) {
+		return
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(p.Get("user_basket")); err != nil {
+		writeJSON(w, map[string]interface{}{"status": "failed", "reason": "user_basket is not base64 encoded"})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"status": "success", "token": randomToken()})
+}
+
+func (s *Server) handleInstallmentRates(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+	if p.Get("request_id") == "" {
+		writeFailed(w, "request_id is required")
+		return
+	}
+	if !s.verify(w, p, p.Get("merchant_id")+p.Get("request_id")) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oranlar := map[string]map[string]string{}
+	for family, table := range s.rates.Families {
+		oranlar[family] = map[string]string{}
+		for count, rate := range table {
+			oranlar[family][fmt.Sprintf("taksit_%d", count)] = rate.String()
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"status":           "success",
+		"request_id":       p.Get("request_id"),
+		"max_inst_non_bus": fmt.Sprint(s.rates.MaxInstallmentsNonBusiness),
+		"oranlar":          oranlar,
+	})
+}
+
+func (s *Server) handlePlatformTransfer(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+
+	hashStr := p.Get("merchant_id") + p.Get("merchant_oid") + p.Get("trans_id") +
+		p.Get("submerchant_amount") + p.Get("total_amount") + p.Get("transfer_name") + p.Get("transfer_iban")
+	if !s.verify(w, p, hashStr) {
+		return
+	}
+
+	s.mu.Lock()
+	order, found := s.orders[p.Get("merchant_oid")]
+	if !found || order.Status != "success" {
+		s.mu.Unlock()
+		writeFailed(w, "order not found or not paid")
+		return
+	}
+	if _, exists := s.transfers[p.Get("trans_id")]; exists || p.Get("trans_id") == "" {
+		s.mu.Unlock()
+		writeFailed(w, "trans_id has already been used")
+		return
+	}
+
+	submerchantMinor, err1 := strconv.ParseInt(p.Get("submerchant_amount"), 10, 64)
+	totalMinor, err2 := strconv.ParseInt(p.Get("total_amount"), 10, 64)
+	if err1 != nil || err2 != nil || submerchantMinor <= 0 || totalMinor != order.Amount.Minor {
+		s.mu.Unlock()
+		writeFailed(w, "invalid submerchant_amount or total_amount")
+		return
+	}
+
+	transferred := domain.NewMoney(submerchantMinor, order.Amount.Currency)
+	for _, t := range s.transfers {
+		if t.MerchantOid == order.MerchantOid {
+			transferred = transferred.Add(t.SubmerchantAmount)
+		}
+	}
+	if transferred.Cmp(order.Amount) > 0 {
+		s.mu.Unlock()
+		writeFailed(w, "submerchant_amount exceeds the transferable amount")
+		return
+	}
+
+	s.transfers[p.Get("trans_id")] = &Transfer{
+		TransID:           p.Get("trans_id"),
+		MerchantOid:       order.MerchantOid,
+		SubmerchantAmount: domain.NewMoney(submerchantMinor, order.Amount.Currency),
+		TotalAmount:       order.Amount,
+		TransferName:      p.Get("transfer_name"),
+		TransferIBAN:      p.Get("transfer_iban"),
+		CreatedAt:         s.Now(),
+	}
+	s.mu.Unlock()
+
+	s.notifyTransfers([]string{p.Get("trans_id")})
+	writeJSON(w, map[string]interface{}{"status": "success"})
+}
+
+func (s *Server) handleReturnedTransfers(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.params(w, r)
+	if !ok {
+		return
+	}
+	if !s.verify(w, p, p.Get("merchant_id")+p.Get("start_date")+p.Get("end_date")) {
+		return
+	}
+
+	start, err := parseDate(p.Get("start_date"), false)
+	if err != nil {
+		writeError(w, "invalid start_date")
+		return
+	}
+	end, err := parseDate(p.Get("end_date"), true)
+	if err != nil {
+		writeError(w, "invalid end_date")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var returned []*Transfer
+	for _, t := range s.transfers {
+		if !t.ReturnedAt.IsZero() && inRange(t.ReturnedAt, start, end) {
+			returned = append(returned, t)
+		}
+	}
+	sort.Slice(returned, func(i, j int) bool { return returned[i].ReturnedAt.Before(returned[j].ReturnedAt) })
+	if len(returned) == 0 {
+		writeFailed(w, "no returned transfers in the period")
+		return
+	}
+
+	data := make([]map[string]interface{}, len(returned))
+	for i, t := range returned {
+		data[i] = map[string]interface{}{
+			"ref_no":            t.TransID,
+			"date_detected":     t.ReturnedAt.In(domain.PayTRLocation).Format(domain.ReportTimeFormat),
+			"date_reimbursed":   t.ReturnedAt.In(domain.PayTRLocation).Format(domain.ReportTimeFormat),
+			"transfer_name":     t.TransferName,
+			"transfer_iban":     t.TransferIBAN,
+			"transfer_amount":   t.SubmerchantAmount.String(),
+			"transfer_currency": t.SubmerchantAmount.Currency,
+			"transfer_date":     t.CreatedAt.In(domain.PayTRLocation).Format(domain.ReportTimeFormat),
+		}
+	}
+	writeJSON(w, map[string]interface{}{"status": "success", "data": data})
+}
+
+// HELPERS
+
+// params reads the request fields from a form-urlencoded or JSON body.
+func (s *Server) params(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return nil, false
+		}
+		values := url.Values{}
+		for key, value := range body {
+			switch v := value.(type) {
+			case string:
+				values.Set(key, v)
+			case nil:
+			default:
+				encoded, _ := json.Marshal(v)
+				values.Set(key, string(encoded))
+			}
+		}
+		return values, true
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form body", http.StatusBadRequest)
+		return nil, false
+	}
+	return r.PostForm, true
+}
+
+// verify checks the merchant_id and paytr_token of a request. hashStr is the
+// endpoint specific message the token is computed over, without the salt.
+func (s *Server) verify(w http.ResponseWriter, p url.Values, hashStr string) bool {
+	if id := p.Get("merchant_id"); id != "" && id != s.config.MerchantID {
+		writeFailed(w, "merchant_id is invalid")
+		return false
+	}
+	if !hmac.Equal([]byte(s.token(hashStr)), []byte(p.Get("paytr_token"))) {
+		writeFailed(w, "paytr_token is invalid")
+		return false
+	}
+	return true
+}
+
+func (s *Server) token(data string) string {
+	h := hmac.New(sha256.New, []byte(s.config.MerchantKey))
+	h.Write([]byte(data + s.config.MerchantSalt))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// notify posts the payment notification of order to NotifyURL.
+func (s *Server) notify(order Order) {
+	if s.NotifyURL == "" {
+		return
+	}
+
+	total := order.Amount.MinorString()
+	h := hmac.New(sha256.New, []byte(s.config.MerchantKey))
+	h.Write([]byte(order.MerchantOid + s.config.MerchantSalt + order.Status + total))
+
+	form := url.Values{
+		"merchant_oid":      {order.MerchantOid},
+		"status":            {order.Status},
+		"total_amount":      {total},
+		"hash":              {base64.StdEncoding.EncodeToString(h.Sum(nil))},
+		"payment_type":      {order.PaymentType},
+		"currency":          {order.Amount.Currency},
+		"payment_amount":    {total},
+		"installment_count": {order.InstallmentCount},
+		"test_mode":         {order.TestMode},
+	}
+	if order.Status != "success" {
+		form.Set("failed_reason_code", "0")
+		form.Set("failed_reason_msg", order.FailedReason)
+	}
+
+	resp, err := s.client.PostForm(s.NotifyURL, form)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// notifyTransfers posts the result of the given transfers to TransferNotifyURL.
+func (s *Server) notifyTransfers(transIDs []string) {
+	if s.TransferNotifyURL == "" {
+		return
+	}
+
+	encoded, _ := json.Marshal(transIDs)
+	form := url.Values{
+		"trans_ids": {string(encoded)},
+		"hash":      {s.token(string(encoded))},
+	}
+
+	resp, err := s.client.PostForm(s.TransferNotifyURL, form)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+func (s *Server) findCard(utoken, ctoken string) (Card, bool) {
+	for _, card := range s.cards[utoken] {
+		if card.CToken == ctoken {
+			return card, true
+		}
+	}
+	return Card{}, false
+}
+
+func transaction(order *Order, kind string, amount domain.Money, at time.Time) domain.Transaction {
+	return domain.Transaction{
+		IslemTipi:     kind,
+		NetTutar:      amount.String(),
+		KesintiTutari: "0.00",
+		KesintiOrani:  "0",
+		IslemTutari:   amount.String(),
+		OdemeTutari:   amount.String(),
+		IslemTarihi:   at.In(domain.PayTRLocation).Format(domain.ReportTimeFormat),
+		ParaBirimi:    amount.Currency,
+		Taksit:        order.InstallmentCount,
+		KartMarka:     order.CardBrand,
+		KartNo:        maskPan(order.CardNumber),
+		SiparisNo:     order.MerchantOid,
+		OdemeTipi:     order.PaymentType,
+	}
+}
+
+// parseDate accepts "2006-01-02 15:04:05" and "2006-01-02". A date without a time
+// covers the whole day when end is true.
+func parseDate(value string, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(domain.ReportTimeFormat, value, domain.PayTRLocation); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, domain.PayTRLocation)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
+
+func inRange(t, start, end time.Time) bool {
+	return !t.Before(start) && !t.After(end)
+}
+
+func defaultBins() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"411111": {"status": "success", "cardType": "credit", "businessCard": "n", "bank": "Test Bank", "brand": "world", "schema": "VISA", "allow_non3d": "Y", "installment": "Y"},
+		"555555": {"status": "success", "cardType": "credit", "businessCard": "n", "bank": "Test Bank", "brand": "bonus", "schema": "MASTERCARD", "allow_non3d": "Y", "installment": "Y"},
+		"979200": {"status": "success", "cardType": "debit", "businessCard": "n", "bank": "Test Bank", "brand": "none", "schema": "TROY", "allow_non3d": "N", "installment": "N"},
+	}
+}
+
+func defaultInstallmentRates() domain.InstallmentRates {
+	table := domain.InstallmentRateTable{2: 300, 3: 450, 6: 850, 9: 1250, 12: 1650}
+	return domain.InstallmentRates{
+		MaxInstallmentsNonBusiness: 12,
+		Families:                   map[string]domain.InstallmentRateTable{"world": table, "bonus": table},
+	}
+}
+
+func brandOf(cardNumber string) string {
+	switch {
+	case strings.HasPrefix(cardNumber, "4"):
+		return "visa"
+	case strings.HasPrefix(cardNumber, "5"), strings.HasPrefix(cardNumber, "2"):
+		return "master"
+	case strings.HasPrefix(cardNumber, "34"), strings.HasPrefix(cardNumber, "37"):
+		return "amex"
+	case strings.HasPrefix(cardNumber, "9792"), strings.HasPrefix(cardNumber, "65"):
+		return "troy"
+	default:
+		return ""
+	}
+}
+
+func maskPan(cardNumber string) string {
+	if len(cardNumber) < 10 {
+		return cardNumber
+	}
+	return cardNumber[:6] + strings.Repeat("*", len(cardNumber)-10) + cardNumber[len(cardNumber)-4:]
+}
+
+func lastFour(cardNumber string) string {
+	if len(cardNumber) < 4 {
+		return cardNumber
+	}
+	return cardNumber[len(cardNumber)-4:]
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("paytrtest: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeFailed(w http.ResponseWriter, message string) {
+	writeJSON(w, map[string]interface{}{"status": "failed", "message": message, "reason": message})
+}
+
+// writeError answers like PayTR's reports do when a request is invalid, as opposed to
+// "failed", which they use for periods without results.
+func writeError(w http.ResponseWriter, message string) {
+	writeJSON(w, map[string]interface{}{"status": "error", "err_no": "1", "err_msg": message})
+}
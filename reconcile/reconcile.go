@@ -0,0 +1,223 @@
+// Package reconcile compares PayTR's transaction reports with the payments recorded
+// locally through a domain.PaymentRepository, so that differences between the
+// merchant panel and the merchant's own records are found without checking by hand.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sort"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// Kind is the kind of a money movement.
+type Kind = domain.TransactionKind
+
+const (
+	KindSale   = domain.TransactionKindSale
+	KindRefund = domain.TransactionKindRefund
+)
+
+// TransactionSource provides PayTR's transaction reports for periods of any length.
+// payment.Service implements it, splitting the period into the windows PayTR accepts.
+type TransactionSource interface {
+	GetTransactionDetailsRangeContext(ctx context.Context, from, to time.Time) iter.Seq2[domain.Transaction, error]
+}
+
+// Entry is a sale or refund, either reported by PayTR or recorded locally.
+type Entry struct {
+	MerchantOid string
+	Kind        Kind
+	Amount      domain.Money
+	At          time.Time
+
+	// Transaction is the reported transaction, for entries reported by PayTR.
+	Transaction *domain.Transaction
+
+	// Payment is the local payment record. It is also set for entries missing locally
+	// when the payment was recorded but never succeeded locally, e.g. because its
+	// notification was lost.
+	Payment *domain.Payment
+}
+
+// Match pairs an entry reported by PayTR with one recorded locally.
+type Match struct {
+	PayTR Entry
+	Local Entry
+}
+
+// Report is the result of a reconciliation. Entries are ordered by time.
+type Report struct {
+	From, To time.Time
+
+	// Matched entries agree on merchant_oid, kind and amount.
+	Matched []Match
+
+	// AmountMismatches agree on merchant_oid and kind but not on the amount.
+	AmountMismatches []Match
+
+	// MissingLocally are reported by PayTR but not recorded locally.
+	MissingLocally []Entry
+
+	// MissingAtPayTR are recorded locally but not reported by PayTR.
+	MissingAtPayTR []Entry
+
+	// Skipped are reported transactions that are neither sales nor refunds.
+	Skipped []domain.Transaction
+}
+
+// OK reports whether every entry was matched.
+func (r *Report) OK() bool {
+	return len(r.AmountMismatches) == 0 && len(r.MissingLocally) == 0 && len(r.MissingAtPayTR) == 0
+}
+
+// Reconciler compares the transactions PayTR reports for a period with the local records.
+type Reconciler struct {
+	transactions TransactionSource
+	payments     domain.PaymentRepository
+}
+
+// New creates a Reconciler that reads reports from transactions and local records from payments.
+func New(transactions TransactionSource, payments domain.PaymentRepository) *Reconciler {
+	return &Reconciler{transactions: transactions, payments: payments}
+}
+
+// Reconcile compares the sales and refunds in [from, to) reported by PayTR with the
+// payments that succeeded and the refunds recorded locally in the same period.
+//
+// Entries are matched by merchant_oid and kind, first with equal amounts and then,
+// for what is left, in time order as amount mismatches. Movements close to the bounds
+// may be dated differently by PayTR and locally; reconcile overlapping periods or
+// recheck such entries before acting on them.
+func (r *Reconciler) Reconcile(ctx context.Context, from, to time.Time) (*Report, error) {
+	remote, skipped, err := r.remoteEntries(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	local, err := r.localEntries(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{From: from, To: to, Skipped: skipped}
+	match(report, remote, local)
+
+	for i, entry := range report.MissingLocally {
+		if payment, err := r.payments.FindPayment(ctx, entry.MerchantOid); err == nil {
+			report.MissingLocally[i].Payment = payment
+		}
+	}
+	return report, nil
+}
+
+func (r *Reconciler) remoteEntries(ctx context.Context, from, to time.Time) ([]Entry, []domain.Transaction, error) {
+	var entries []Entry
+	var skipped []domain.Transaction
+	for transaction, err := range r.transactions.GetTransactionDetailsRangeContext(ctx, from, to) {
+		if err != nil {
+			return nil, nil, err
+		}
+		t := &transaction
+
+		if t.Kind() == domain.TransactionKindOther {
+			skipped = append(skipped, *t)
+			continue
+		}
+		view, err := t.View()
+		if err != nil {
+			return nil, nil, fmt.Errorf("transaction %s: %v", t.SiparisNo, err)
+		}
+
+		entries = append(entries, Entry{MerchantOid: t.SiparisNo, Kind: view.Kind, Amount: view.Amount, At: view.OccurredAt, Transaction: t})
+	}
+	return entries, skipped, nil
+}
+
+func (r *Reconciler) localEntries(ctx context.Context, from, to time.Time) ([]Entry, error) {
+	payments, err := r.payments.ListPaymentsUpdatedSince(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for i := range payments {
+		payment := &payments[i]
+
+		if at, ok := payment.StatusAt(domain.PaymentStatusSucceeded); ok && inRange(at, from, to) {
+			entries = append(entries, Entry{MerchantOid: payment.MerchantOid, Kind: KindSale, Amount: payment.Amount, At: at, Payment: payment})
+		}
+		for _, refund := range payment.Refunds() {
+			if inRange(refund.At, from, to) {
+				entries = append(entries, Entry{MerchantOid: payment.MerchantOid, Kind: KindRefund, Amount: refund.Amount, At: refund.At, Payment: payment})
+			}
+		}
+	}
+	return entries, nil
+}
+
+type matchKey struct {
+	merchantOid string
+	kind        Kind
+}
+
+func match(report *Report, remote, local []Entry) {
+	sortEntries(remote)
+	sortEntries(local)
+
+	pending := map[matchKey][]Entry{}
+	for _, entry := range local {
+		key := matchKey{entry.MerchantOid, entry.Kind}
+		pending[key] = append(pending[key], entry)
+	}
+
+	// Pair equal amounts first, so that the order of refunds of different amounts does not matter.
+	var unmatched []Entry
+	for _, entry := range remote {
+		key := matchKey{entry.MerchantOid, entry.Kind}
+		candidates := pending[key]
+		found := -1
+		for i, candidate := range candidates {
+			if candidate.Amount.Minor == entry.Amount.Minor {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			unmatched = append(unmatched, entry)
+			continue
+		}
+		report.Matched = append(report.Matched, Match{PayTR: entry, Local: candidates[found]})
+		pending[key] = append(candidates[:found:found], candidates[found+1:]...)
+	}
+
+	for _, entry := range unmatched {
+		key := matchKey{entry.MerchantOid, entry.Kind}
+		if candidates := pending[key]; len(candidates) > 0 {
+			report.AmountMismatches = append(report.AmountMismatches, Match{PayTR: entry, Local: candidates[0]})
+			pending[key] = candidates[1:]
+			continue
+		}
+		report.MissingLocally = append(report.MissingLocally, entry)
+	}
+
+	for _, entries := range pending {
+		report.MissingAtPayTR = append(report.MissingAtPayTR, entries...)
+	}
+	sortEntries(report.MissingAtPayTR)
+}
+
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].At.Equal(entries[j].At) {
+			return entries[i].At.Before(entries[j].At)
+		}
+		return entries[i].MerchantOid < entries[j].MerchantOid
+	})
+}
+
+func inRange(t, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
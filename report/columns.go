@@ -0,0 +1,79 @@
+package report
+
+import (
+	"io"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// TransactionColumns are the columns available for transactions, in their default order.
+var TransactionColumns = []Column[domain.TransactionView]{
+	{"kind", "Type", "İşlem Tipi", func(v domain.TransactionView) interface{} { return v.Kind }},
+	{"merchant_oid", "Order No", "Sipariş No", func(v domain.TransactionView) interface{} { return v.MerchantOid }},
+	{"occurred_at", "Date", "İşlem Tarihi", func(v domain.TransactionView) interface{} { return v.OccurredAt }},
+	{"amount", "Amount", "İşlem Tutarı", func(v domain.TransactionView) interface{} { return v.Amount }},
+	{"paid_amount", "Paid Amount", "Ödeme Tutarı", func(v domain.TransactionView) interface{} { return v.PaidAmount }},
+	{"fee_amount", "Fee", "Kesinti Tutarı", func(v domain.TransactionView) interface{} { return v.FeeAmount }},
+	{"fee_rate", "Fee Rate", "Kesinti Oranı", func(v domain.TransactionView) interface{} { return v.FeeRate }},
+	{"net_amount", "Net Amount", "Net Tutar", func(v domain.TransactionView) interface{} { return v.NetAmount }},
+	{"currency", "Currency", "Para Birimi", func(v domain.TransactionView) interface{} { return v.Currency }},
+	{"installments", "Installments", "Taksit", func(v domain.TransactionView) interface{} { return v.Installments }},
+	{"card_brand", "Card Brand", "Kart Marka", func(v domain.TransactionView) interface{} { return v.CardBrand }},
+	{"masked_pan", "Card Number", "Kart No", func(v domain.TransactionView) interface{} { return v.MaskedPan }},
+	{"payment_type", "Payment Type", "Ödeme Tipi", func(v domain.TransactionView) interface{} { return v.PaymentType }},
+}
+
+// StatusRecord is the result of a status inquiry for a merchant_oid.
+type StatusRecord struct {
+	MerchantOid string
+	Response    domain.StatusInquiryResponse
+}
+
+// StatusRow is the exported view of a StatusRecord.
+type StatusRow struct {
+	MerchantOid string
+	domain.StatusView
+}
+
+// StatusColumns are the columns available for status inquiries, in their default order.
+var StatusColumns = []Column[StatusRow]{
+	{"merchant_oid", "Order No", "Sipariş No", func(r StatusRow) interface{} { return r.MerchantOid }},
+	{"status", "Status", "Durum", func(r StatusRow) interface{} { return r.Status }},
+	{"paid_at", "Payment Date", "Ödeme Tarihi", func(r StatusRow) interface{} { return r.PaidAt }},
+	{"amount", "Amount", "Ödeme Tutarı", func(r StatusRow) interface{} { return r.Amount }},
+	{"total", "Total", "Toplam Tutar", func(r StatusRow) interface{} { return r.Total }},
+	{"fee_amount", "Fee", "Kesinti Tutarı", func(r StatusRow) interface{} { return r.FeeAmount }},
+	{"net_amount", "Net Amount", "Net Tutar", func(r StatusRow) interface{} { return r.NetAmount }},
+	{"currency", "Currency", "Para Birimi", func(r StatusRow) interface{} { return r.Currency }},
+	{"installments", "Installments", "Taksit", func(r StatusRow) interface{} { return r.Installments }},
+	{"card_brand", "Card Brand", "Kart Marka", func(r StatusRow) interface{} { return r.CardBrand }},
+	{"masked_pan", "Card Number", "Kart No", func(r StatusRow) interface{} { return r.MaskedPan }},
+	{"payment_type", "Payment Type", "Ödeme Tipi", func(r StatusRow) interface{} { return r.PaymentType }},
+	{"test_mode", "Test Mode", "Test Modu", func(r StatusRow) interface{} { return r.TestMode }},
+}
+
+// turkishKinds translates transaction kinds for Turkish exports.
+var turkishKinds = map[domain.TransactionKind]string{
+	domain.TransactionKindSale:   "Satış",
+	domain.TransactionKindRefund: "İade",
+	domain.TransactionKindOther:  "Diğer",
+}
+
+// NewTransactionEncoder creates an encoder for transactions.
+func NewTransactionEncoder(w io.Writer, format Format, opts Options) (*Encoder[domain.TransactionView], error) {
+	columns := TransactionColumns
+	if opts.Language == Turkish {
+		columns = append([]Column[domain.TransactionView](nil), columns...)
+		for i := range columns {
+			if columns[i].Key == "kind" {
+				columns[i].Value = func(v domain.TransactionView) interface{} { return turkishKinds[v.Kind] }
+			}
+		}
+	}
+	return newEncoder(w, format, opts, columns)
+}
+
+// NewStatusEncoder creates an encoder for status inquiries.
+func NewStatusEncoder(w io.Writer, format Format, opts Options) (*Encoder[StatusRow], error) {
+	return newEncoder(w, format, opts, StatusColumns)
+}
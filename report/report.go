@@ -0,0 +1,334 @@
+// Package report exports PayTR transaction reports and status inquiries to CSV and
+// JSON Lines files, with a choice of columns, English or Turkish headers and
+// locale-specific number and date formats, e.g. for import into accounting software.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// Format is an output file format.
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+// Language selects the language of headers and of values such as transaction kinds.
+type Language string
+
+const (
+	English Language = "en"
+	Turkish Language = "tr"
+)
+
+// Options controls the columns and formatting of an export. The zero value exports
+// every column with English headers, dot decimals and PayTR's date format.
+type Options struct {
+	// Columns lists the keys of the exported columns, in order. All columns are exported when empty.
+	Columns []string
+
+	// Language of the headers and of translated values. English by default.
+	Language Language
+
+	// DecimalSeparator separates the decimals of amounts and rates. '.' by default.
+	DecimalSeparator rune
+
+	// ThousandsSeparator groups the digits of amounts, e.g. '.' for "1.234,56". None by default.
+	ThousandsSeparator rune
+
+	// TimeLayout formats dates. domain.ReportTimeFormat by default.
+	TimeLayout string
+
+	// Location of formatted dates. domain.PayTRLocation by default.
+	Location *time.Location
+
+	// Comma is the CSV field delimiter. ',' by default; ';' is usual with decimal commas.
+	Comma rune
+
+	// NoHeader omits the CSV header row.
+	NoHeader bool
+}
+
+// TurkishOptions formats numbers and dates as Turkish spreadsheets expect them,
+// e.g. "1.234,56" and "01.05.2024 14:30:00", with Turkish headers.
+var TurkishOptions = Options{
+	Language:           Turkish,
+	DecimalSeparator:   ',',
+	ThousandsSeparator: '.',
+	TimeLayout:         "02.01.2006 15:04:05",
+	Comma:              ';',
+}
+
+func (o Options) withDefaults() Options {
+	if o.Language == "" {
+		o.Language = English
+	}
+	if o.DecimalSeparator == 0 {
+		o.DecimalSeparator = '.'
+	}
+	if o.TimeLayout == "" {
+		o.TimeLayout = domain.ReportTimeFormat
+	}
+	if o.Location == nil {
+		o.Location = domain.PayTRLocation
+	}
+	if o.Comma == 0 {
+		o.Comma = ','
+	}
+	return o
+}
+
+// Column is an exported column of records of type T.
+type Column[T any] struct {
+	Key     string
+	English string
+	Turkish string
+	Value   func(T) interface{}
+}
+
+func (c Column[T]) header(lang Language) string {
+	if lang == Turkish {
+		return c.Turkish
+	}
+	return c.English
+}
+
+// Encoder writes records of type T to a CSV or JSON Lines file.
+type Encoder[T any] struct {
+	format  Format
+	opts    Options
+	columns []Column[T]
+	csv     *csv.Writer
+	json    *json.Encoder
+	started bool
+}
+
+func newEncoder[T any](w io.Writer, format Format, opts Options, all []Column[T]) (*Encoder[T], error) {
+	opts = opts.withDefaults()
+
+	columns := all
+	if len(opts.Columns) > 0 {
+		columns = make([]Column[T], 0, len(opts.Columns))
+		for _, key := range opts.Columns {
+			column, ok := findColumn(all, key)
+			if !ok {
+				return nil, fmt.Errorf("report: unknown column %q", key)
+			}
+			columns = append(columns, column)
+		}
+	}
+
+	e := &Encoder[T]{format: format, opts: opts, columns: columns}
+	switch format {
+	case FormatCSV:
+		e.csv = csv.NewWriter(w)
+		e.csv.Comma = opts.Comma
+	case FormatJSONL:
+		e.json = json.NewEncoder(w)
+		e.json.SetEscapeHTML(false)
+	default:
+		return nil, fmt.Errorf("report: unknown format %q", format)
+	}
+	return e, nil
+}
+
+func findColumn[T any](columns []Column[T], key string) (Column[T], bool) {
+	for _, c := range columns {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return Column[T]{}, false
+}
+
+// Write writes a record. The CSV header is written before the first record.
+func (e *Encoder[T]) Write(record T) error {
+	if e.json != nil {
+		row := make(map[string]interface{}, len(e.columns))
+		for _, c := range e.columns {
+			row[c.Key] = e.jsonValue(c.Value(record))
+		}
+		return e.json.Encode(orderedRow{keys: e.keys(), values: row})
+	}
+
+	if !e.started && !e.opts.NoHeader {
+		header := make([]string, len(e.columns))
+		for i, c := range e.columns {
+			header[i] = c.header(e.opts.Language)
+		}
+		if err := e.csv.Write(header); err != nil {
+			return err
+		}
+	}
+	e.started = true
+
+	fields := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		fields[i] = e.text(c.Value(record))
+	}
+	return e.csv.Write(fields)
+}
+
+// Flush writes buffered data to the underlying writer. It must be called after the last record.
+func (e *Encoder[T]) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+func (e *Encoder[T]) keys() []string {
+	keys := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		keys[i] = c.Key
+	}
+	return keys
+}
+
+// text formats a value for CSV.
+func (e *Encoder[T]) text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case domain.Money:
+		return e.amount(v)
+	case float64:
+		return strings.Replace(strconv.FormatFloat(v, 'f', 2, 64), ".", string(e.opts.DecimalSeparator), 1)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.In(e.opts.Location).Format(e.opts.TimeLayout)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// jsonValue keeps numbers and booleans as JSON values and formats amounts and dates as strings.
+func (e *Encoder[T]) jsonValue(v interface{}) interface{} {
+	switch v.(type) {
+	case int, float64, bool:
+		return v
+	}
+	return e.text(v)
+}
+
+// amount formats m with the configured separators, e.g. "1.234,56".
+func (e *Encoder[T]) amount(m domain.Money) string {
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	units := strconv.FormatInt(minor/100, 10)
+	if e.opts.ThousandsSeparator != 0 {
+		var b strings.Builder
+		for i, d := range units {
+			if i > 0 && (len(units)-i)%3 == 0 {
+				b.WriteRune(e.opts.ThousandsSeparator)
+			}
+			b.WriteRune(d)
+		}
+		units = b.String()
+	}
+	return fmt.Sprintf("%s%s%c%02d", sign, units, e.opts.DecimalSeparator, minor%100)
+}
+
+// orderedRow encodes a JSON object with its keys in column order.
+type orderedRow struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// WriteTransactions exports transactions, e.g. the result of GetTransactionDetails.
+func WriteTransactions(w io.Writer, format Format, opts Options, transactions []domain.Transaction) error {
+	return WriteTransactionSeq(w, format, opts, func(yield func(domain.Transaction, error) bool) {
+		for _, t := range transactions {
+			if !yield(t, nil) {
+				return
+			}
+		}
+	})
+}
+
+// WriteTransactionSeq exports transactions as they are yielded, e.g. by GetTransactionDetailsRange.
+// It stops at the first error.
+func WriteTransactionSeq(w io.Writer, format Format, opts Options, transactions iter.Seq2[domain.Transaction, error]) error {
+	e, err := NewTransactionEncoder(w, format, opts)
+	if err != nil {
+		return err
+	}
+	for t, err := range transactions {
+		if err != nil {
+			return err
+		}
+		view, err := t.View()
+		if err != nil {
+			return fmt.Errorf("transaction %s: %v", t.SiparisNo, err)
+		}
+		if err := e.Write(view); err != nil {
+			return err
+		}
+	}
+	return e.Flush()
+}
+
+// WriteStatuses exports status inquiry results.
+func WriteStatuses(w io.Writer, format Format, opts Options, statuses []StatusRecord) error {
+	e, err := NewStatusEncoder(w, format, opts)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		view, err := s.Response.View()
+		if err != nil {
+			return fmt.Errorf("status of %s: %v", s.MerchantOid, err)
+		}
+		if err := e.Write(StatusRow{MerchantOid: s.MerchantOid, StatusView: view}); err != nil {
+			return err
+		}
+	}
+	return e.Flush()
+}
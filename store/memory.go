@@ -0,0 +1,159 @@
+// Package store provides implementations of the domain.PaymentRepository and
+// domain.CardRepository interfaces: an in-memory store for tests and single-process
+// deployments, and a database/sql store for anything that must survive a restart.
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// Memory is an in-memory, concurrency-safe implementation of domain.PaymentRepository
+// and domain.CardRepository. The zero value is not usable; create one with NewMemory.
+type Memory struct {
+	mu       sync.RWMutex
+	seq      int
+	payments map[string]memoryRecord[domain.Payment]
+	cards    map[cardKey]memoryRecord[domain.SavedCard]
+}
+
+type memoryRecord[T any] struct {
+	seq   int
+	value T
+}
+
+type cardKey struct {
+	utoken, ctoken string
+}
+
+// NewMemory creates an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		payments: map[string]memoryRecord[domain.Payment]{},
+		cards:    map[cardKey]memoryRecord[domain.SavedCard]{},
+	}
+}
+
+func (m *Memory) SavePayment(ctx context.Context, payment *domain.Payment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.payments[payment.MerchantOid]
+	if ok != (payment.Version != 0) || record.value.Version != payment.Version {
+		return domain.ErrConflict
+	}
+	if !ok {
+		m.seq++
+		record.seq = m.seq
+	}
+	if payment.ID == "" {
+		payment.ID = payment.MerchantOid
+	}
+	payment.Version++
+	record.value = *payment
+	record.value.History = append([]domain.StatusChange(nil), payment.History...)
+	m.payments[payment.MerchantOid] = record
+	return nil
+}
+
+func (m *Memory) FindPayment(ctx context.Context, merchantOid string) (*domain.Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, ok := m.payments[merchantOid]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	payment := record.value
+	payment.History = append([]domain.StatusChange(nil), payment.History...)
+	return &payment, nil
+}
+
+func (m *Memory) ListPayments(ctx context.Context, userID string) ([]domain.Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var records []memoryRecord[domain.Payment]
+	for _, record := range m.payments {
+		if record.value.UserID == userID {
+			records = append(records, record)
+		}
+	}
+	return values(records), nil
+}
+
+func (m *Memory) ListPaymentsUpdatedSince(ctx context.Context, since time.Time) ([]domain.Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var payments []domain.Payment
+	for _, record := range m.payments {
+		if !record.value.UpdatedAt.Before(since) {
+			payments = append(payments, record.value)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		if !payments[i].UpdatedAt.Equal(payments[j].UpdatedAt) {
+			return payments[i].UpdatedAt.Before(payments[j].UpdatedAt)
+		}
+		return payments[i].MerchantOid < payments[j].MerchantOid
+	})
+	return payments, nil
+}
+
+func (m *Memory) SaveCard(ctx context.Context, card *domain.SavedCard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if card.ID == "" {
+		card.ID = card.CToken
+	}
+	key := cardKey{card.UToken, card.CToken}
+	record, ok := m.cards[key]
+	if !ok {
+		m.seq++
+		record.seq = m.seq
+	}
+	record.value = *card
+	m.cards[key] = record
+	return nil
+}
+
+func (m *Memory) ListCards(ctx context.Context, utoken string) ([]domain.SavedCard, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var records []memoryRecord[domain.SavedCard]
+	for key, record := range m.cards {
+		if key.utoken == utoken {
+			records = append(records, record)
+		}
+	}
+	return values(records), nil
+}
+
+func (m *Memory) DeleteCard(ctx context.Context, utoken, ctoken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := cardKey{utoken, ctoken}
+	if _, ok := m.cards[key]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(m.cards, key)
+	return nil
+}
+
+// values returns the values of records in insertion order.
+func values[T any](records []memoryRecord[T]) []T {
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+	result := make([]T, len(records))
+	for i, record := range records {
+		result[i] = record.value
+	}
+	return result
+}
@@ -0,0 +1,350 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+// migrations create and evolve the SQL schema. Each entry is applied once, in order,
+// and recorded in the paytr_schema_migrations table. Never edit an applied migration;
+// append a new one instead.
+var migrations = []string{
+	`CREATE TABLE paytr_payments (
+		id              TEXT NOT NULL PRIMARY KEY,
+		merchant_oid    TEXT NOT NULL UNIQUE,
+		user_id         TEXT NOT NULL DEFAULT '',
+		amount_minor    INTEGER NOT NULL,
+		amount_currency TEXT NOT NULL DEFAULT '',
+		currency        TEXT NOT NULL DEFAULT '',
+		status          TEXT NOT NULL,
+		payment_method  TEXT NOT NULL DEFAULT '',
+		created_at      TEXT NOT NULL,
+		updated_at      TEXT NOT NULL
+	)`,
+	`CREATE INDEX paytr_payments_user_id ON paytr_payments (user_id)`,
+	`CREATE TABLE paytr_saved_cards (
+		id          TEXT NOT NULL PRIMARY KEY,
+		user_id     TEXT NOT NULL DEFAULT '',
+		utoken      TEXT NOT NULL,
+		ctoken      TEXT NOT NULL,
+		last_four   TEXT NOT NULL DEFAULT '',
+		card_type   TEXT NOT NULL DEFAULT '',
+		expiry_date TEXT NOT NULL DEFAULT '',
+		month       TEXT NOT NULL DEFAULT '',
+		year        TEXT NOT NULL DEFAULT '',
+		bank        TEXT NOT NULL DEFAULT '',
+		owner_name  TEXT NOT NULL DEFAULT '',
+		brand       TEXT NOT NULL DEFAULT '',
+		schema      TEXT NOT NULL DEFAULT '',
+		require_cvv INTEGER NOT NULL DEFAULT 0,
+		created_at  TEXT NOT NULL,
+		UNIQUE (utoken, ctoken)
+	)`,
+	`ALTER TABLE paytr_payments ADD COLUMN refunded_minor INTEGER NOT NULL DEFAULT 0`,
+	`CREATE TABLE paytr_payment_history (
+		merchant_oid TEXT NOT NULL,
+		seq          INTEGER NOT NULL,
+		from_status  TEXT NOT NULL,
+		to_status    TEXT NOT NULL,
+		at           TEXT NOT NULL,
+		reason       TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (merchant_oid, seq)
+	)`,
+	`ALTER TABLE paytr_payment_history ADD COLUMN amount_minor INTEGER NOT NULL DEFAULT 0`,
+	`CREATE INDEX paytr_payments_updated_at ON paytr_payments (updated_at)`,
+	`ALTER TABLE paytr_payments ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+}
+
+// SQL implements domain.PaymentRepository and domain.CardRepository on top of database/sql.
+// Its schema and queries target SQLite (3.24 or later, for upserts) and use ? placeholders.
+// Call Migrate before first use.
+type SQL struct {
+	db *sql.DB
+}
+
+// NewSQL creates a store backed by db.
+func NewSQL(db *sql.DB) *SQL {
+	return &SQL{db: db}
+}
+
+// Migrate creates or upgrades the tables used by the store. It is safe to call on every start.
+func (s *SQL) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS paytr_schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("error creating migrations table: %v", err)
+	}
+
+	var applied int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM paytr_schema_migrations`).Scan(&applied); err != nil {
+		return fmt.Errorf("error reading schema version: %v", err)
+	}
+
+	for version := applied; version < len(migrations); version++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error applying migration %d: %v", version+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO paytr_schema_migrations (version) VALUES (?)`, version+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error recording migration %d: %v", version+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SavePayment stores the payment and its status history in a single transaction.
+// The row is inserted if payment.Version is zero and updated only if its version still
+// equals payment.Version; otherwise domain.ErrConflict is returned.
+func (s *SQL) SavePayment(ctx context.Context, payment *domain.Payment) error {
+	id := payment.ID
+	if id == "" {
+		id = payment.MerchantOid
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if payment.Version == 0 {
+		result, err = tx.ExecContext(ctx, `
+			INSERT INTO paytr_payments (id, merchant_oid, user_id, amount_minor, amount_currency, refunded_minor, currency, status, payment_method, created_at, updated_at, version)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)
+			ON CONFLICT (merchant_oid) DO NOTHING`,
+			id, payment.MerchantOid, payment.UserID, payment.Amount.Minor, payment.Amount.Currency, payment.RefundedAmount.Minor,
+			payment.Currency, string(payment.Status), payment.PaymentMethod, formatTime(payment.CreatedAt), formatTime(payment.UpdatedAt))
+	} else {
+		result, err = tx.ExecContext(ctx, `
+			UPDATE paytr_payments SET
+				user_id = ?,
+				amount_minor = ?,
+				amount_currency = ?,
+				refunded_minor = ?,
+				currency = ?,
+				status = ?,
+				payment_method = ?,
+				updated_at = ?,
+				version = version + 1
+			WHERE merchant_oid = ? AND version = ?`,
+			payment.UserID, payment.Amount.Minor, payment.Amount.Currency, payment.RefundedAmount.Minor,
+			payment.Currency, string(payment.Status), payment.PaymentMethod, formatTime(payment.UpdatedAt),
+			payment.MerchantOid, payment.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return domain.ErrConflict
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM paytr_payment_history WHERE merchant_oid = ?`, payment.MerchantOid); err != nil {
+		return err
+	}
+	for i, change := range payment.History {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO paytr_payment_history (merchant_oid, seq, from_status, to_status, at, reason, amount_minor)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			payment.MerchantOid, i, string(change.From), string(change.To), formatTime(change.At), change.Reason, change.Amount.Minor)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	payment.ID = id
+	payment.Version++
+	return nil
+}
+
+const paymentColumns = `id, merchant_oid, user_id, amount_minor, amount_currency, refunded_minor, currency, status, payment_method, created_at, updated_at, version`
+
+func (s *SQL) FindPayment(ctx context.Context, merchantOid string) (*domain.Payment, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM paytr_payments WHERE merchant_oid = ?`, merchantOid)
+	payment, err := scanPayment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if payment.History, err = s.history(ctx, merchantOid, payment.Amount.Currency); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (s *SQL) ListPayments(ctx context.Context, userID string) ([]domain.Payment, error) {
+	return s.listPayments(ctx, `WHERE user_id = ? ORDER BY created_at, id`, userID)
+}
+
+func (s *SQL) ListPaymentsUpdatedSince(ctx context.Context, since time.Time) ([]domain.Payment, error) {
+	return s.listPayments(ctx, `WHERE updated_at >= ? ORDER BY updated_at, id`, formatTime(since))
+}
+
+func (s *SQL) listPayments(ctx context.Context, where string, args ...interface{}) ([]domain.Payment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+paymentColumns+` FROM paytr_payments `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []domain.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range payments {
+		if payments[i].History, err = s.history(ctx, payments[i].MerchantOid, payments[i].Amount.Currency); err != nil {
+			return nil, err
+		}
+	}
+	return payments, nil
+}
+
+func (s *SQL) history(ctx context.Context, merchantOid string, currency string) ([]domain.StatusChange, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT from_status, to_status, at, reason, amount_minor
+		FROM paytr_payment_history WHERE merchant_oid = ? ORDER BY seq`, merchantOid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []domain.StatusChange
+	for rows.Next() {
+		var change domain.StatusChange
+		var at string
+		if err := rows.Scan(&change.From, &change.To, &at, &change.Reason, &change.Amount.Minor); err != nil {
+			return nil, err
+		}
+		if change.Amount.Minor != 0 {
+			change.Amount.Currency = currency
+		}
+		if change.At, err = parseTime(at); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+func (s *SQL) SaveCard(ctx context.Context, card *domain.SavedCard) error {
+	if card.ID == "" {
+		card.ID = card.CToken
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO paytr_saved_cards (id, user_id, utoken, ctoken, last_four, card_type, expiry_date, month, year, bank, owner_name, brand, schema, require_cvv, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (utoken, ctoken) DO UPDATE SET
+			user_id = excluded.user_id,
+			last_four = excluded.last_four,
+			card_type = excluded.card_type,
+			expiry_date = excluded.expiry_date,
+			month = excluded.month,
+			year = excluded.year,
+			bank = excluded.bank,
+			owner_name = excluded.owner_name,
+			brand = excluded.brand,
+			schema = excluded.schema,
+			require_cvv = excluded.require_cvv`,
+		card.ID, card.UserID, card.UToken, card.CToken, card.LastFour, card.CardType, card.ExpiryDate, card.Month, card.Year,
+		card.Bank, card.OwnerName, card.Brand, card.Schema, card.RequireCVV, formatTime(card.CreatedAt))
+	return err
+}
+
+func (s *SQL) ListCards(ctx context.Context, utoken string) ([]domain.SavedCard, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, utoken, ctoken, last_four, card_type, expiry_date, month, year, bank, owner_name, brand, schema, require_cvv, created_at
+		FROM paytr_saved_cards WHERE utoken = ? ORDER BY created_at, id`, utoken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []domain.SavedCard
+	for rows.Next() {
+		var card domain.SavedCard
+		var createdAt string
+		if err := rows.Scan(&card.ID, &card.UserID, &card.UToken, &card.CToken, &card.LastFour, &card.CardType, &card.ExpiryDate,
+			&card.Month, &card.Year, &card.Bank, &card.OwnerName, &card.Brand, &card.Schema, &card.RequireCVV, &createdAt); err != nil {
+			return nil, err
+		}
+		if card.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+func (s *SQL) DeleteCard(ctx context.Context, utoken, ctoken string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM paytr_saved_cards WHERE utoken = ? AND ctoken = ?`, utoken, ctoken)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row scanner) (*domain.Payment, error) {
+	var payment domain.Payment
+	var createdAt, updatedAt string
+	if err := row.Scan(&payment.ID, &payment.MerchantOid, &payment.UserID, &payment.Amount.Minor, &payment.Amount.Currency,
+		&payment.RefundedAmount.Minor, &payment.Currency, &payment.Status, &payment.PaymentMethod, &createdAt, &updatedAt, &payment.Version); err != nil {
+		return nil, err
+	}
+	payment.RefundedAmount.Currency = payment.Amount.Currency
+	var err error
+	if payment.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if payment.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// timeFormat stores times as fixed-width RFC 3339 text in UTC, which sorts
+// chronologically and needs no driver support.
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stored time %q: %v", s, err)
+	}
+	return t, nil
+}
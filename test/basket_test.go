@@ -0,0 +1,68 @@
+package payment_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+)
+
+func TestBasketEncoding(t *testing.T) {
+	basket := domain.NewBasket().
+		Add("T-Shirt", domain.NewMoney(4950, "TRY"), 2).
+		Add("Socks", domain.NewMoney(1000, "TRY"), 1)
+
+	expected := `[["T-Shirt","49.50",2],["Socks","10.00",1]]`
+	if basket.JSON() != expected {
+		t.Errorf("Expected basket JSON '%s', got '%s'", expected, basket.JSON())
+	}
+
+	if basket.Base64() != base64.StdEncoding.EncodeToString([]byte(expected)) {
+		t.Errorf("Unexpected base64 basket '%s'", basket.Base64())
+	}
+
+	if total := basket.Total(); total.Minor != 10900 || total.Currency != "TRY" {
+		t.Errorf("Expected total 109.00 TRY, got %s %s", total, total.Currency)
+	}
+}
+
+func TestBasketValidate(t *testing.T) {
+	basket := domain.NewBasket().Add("T-Shirt", domain.NewMoney(4950, "TRY"), 2)
+
+	if err := basket.Validate(domain.NewMoney(9900, "TRY")); err != nil {
+		t.Errorf("Expected valid basket, got %v", err)
+	}
+
+	if err := basket.Validate(domain.NewMoney(10000, "TRY")); err == nil {
+		t.Error("Expected an error for a basket total that does not match the payment amount")
+	}
+
+	invalid := domain.NewBasket(domain.BasketItem{Name: "", UnitPrice: domain.NewMoney(0, "TRY"), Quantity: 0})
+	if err := invalid.Validate(domain.NewMoney(0, "TRY")); err == nil {
+		t.Error("Expected an error for a malformed basket item")
+	}
+
+	if err := domain.NewBasket().Validate(domain.NewMoney(100, "TRY")); err == nil {
+		t.Error("Expected an error for an empty basket")
+	}
+
+	mixed := domain.NewBasket().
+		Add("A", domain.NewMoney(100, ""), 1).
+		Add("B", domain.NewMoney(100, "USD"), 1).
+		Add("C", domain.NewMoney(100, "EUR"), 1)
+	if err := mixed.Validate(domain.NewMoney(300, "")); err == nil || !strings.Contains(err.Error(), "item 2: currency EUR does not match USD") {
+		t.Errorf("Expected an error for a basket mixing currencies, got %v", err)
+	}
+
+	req := simulatorPayment("order1", 300)
+	req.PaymentAmount = domain.NewMoney(300, "")
+	req.UserBasket = mixed
+	_, err := setupTestService(&domain.PayTRResponse{Status: "success"}).NewCardPayment(req)
+	var verr *payment.ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("Expected a validation error for a basket mixing currencies, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package payment_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/streamerd/paytr-go/config"
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+)
+
+var callbackConfig = config.PayTRConfig{
+	MerchantID:   "test_merchant",
+	MerchantKey:  "test_key",
+	MerchantSalt: "test_salt",
+}
+
+// notificationHash computes the hash PayTR attaches to a payment notification
+func notificationHash(merchantOid, status, totalAmount string) string {
+	h := hmac.New(sha256.New, []byte(callbackConfig.MerchantKey))
+	h.Write([]byte(merchantOid + callbackConfig.MerchantSalt + status + totalAmount))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func postNotification(handler http.Handler, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/paytr/callback", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCallbackHandler(t *testing.T) {
+	var received domain.PaymentNotification
+	handler := payment.NewCallbackHandler(callbackConfig, func(n domain.PaymentNotification) error {
+		received = n
+		return nil
+	})
+
+	form := url.Values{
+		"merchant_oid":   {"test_order_123"},
+		"status":         {"success"},
+		"total_amount":   {"10000"},
+		"hash":           {notificationHash("test_order_123", "success", "10000")},
+		"payment_type":   {"card"},
+		"currency":       {"TL"},
+		"payment_amount": {"10000"},
+	}
+
+	rec := postNotification(handler, form)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != "OK" {
+		t.Errorf("Expected body 'OK', got '%s'", rec.Body.String())
+	}
+	if received.MerchantOid != "test_order_123" || received.Status != "success" {
+		t.Errorf("Unexpected notification: %+v", received)
+	}
+}
+
+func TestCallbackHandlerRejectsForgedHash(t *testing.T) {
+	called := false
+	handler := payment.NewCallbackHandler(callbackConfig, func(n domain.PaymentNotification) error {
+		called = true
+		return nil
+	})
+
+	form := url.Values{
+		"merchant_oid": {"test_order_123"},
+		"status":       {"success"},
+		"total_amount": {"99999"},
+		"hash":         {notificationHash("test_order_123", "success", "10000")},
+	}
+
+	rec := postNotification(handler, form)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+	if called {
+		t.Error("Expected callback not to be called for a forged notification")
+	}
+}
+
+func TestCallbackHandlerRejectsMalformedRequest(t *testing.T) {
+	handler := payment.NewCallbackHandler(callbackConfig, nil)
+
+	rec := postNotification(handler, url.Values{"merchant_oid": {"test_order_123"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "missing notification field: status") {
+		t.Errorf("Expected the first missing field to be reported, got %q", rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/paytr/callback", nil)
+	getRec := httptest.NewRecorder()
+	handler.ServeHTTP(getRec, req)
+	if getRec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", getRec.Code)
+	}
+}
+
+func TestCallbackHandlerCallbackError(t *testing.T) {
+	handler := payment.NewCallbackHandler(callbackConfig, func(n domain.PaymentNotification) error {
+		return errors.New("database unavailable")
+	})
+
+	form := url.Values{
+		"merchant_oid": {"test_order_123"},
+		"status":       {"failed"},
+		"total_amount": {"10000"},
+		"hash":         {notificationHash("test_order_123", "failed", "10000")},
+	}
+
+	rec := postNotification(handler, form)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package payment_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streamerd/paytr-go/card"
+)
+
+func TestLuhn(t *testing.T) {
+	valid := []string{"4111111111111111", "4111 1111 1111 1111", "5555555555554444", "378282246310005", "9792030000000000"}
+	for _, number := range valid {
+		if !card.Luhn(number) {
+			t.Errorf("Expected %s to pass the Luhn check", number)
+		}
+	}
+
+	invalid := []string{"4111111111111112", "1234", "41111111111111a1", ""}
+	for _, number := range invalid {
+		if card.Luhn(number) {
+			t.Errorf("Expected %s to fail the Luhn check", number)
+		}
+	}
+}
+
+func TestDetectBrand(t *testing.T) {
+	cases := map[string]card.Brand{
+		"4111111111111111": card.BrandVisa,
+		"5555555555554444": card.BrandMaster,
+		"2223003122003222": card.BrandMaster,
+		"378282246310005":  card.BrandAmex,
+		"9792030000000000": card.BrandTroy,
+		"6011111111111117": card.BrandUnknown,
+	}
+	for number, expected := range cases {
+		if brand := card.DetectBrand(number); brand != expected {
+			t.Errorf("DetectBrand(%s): expected '%s', got '%s'", number, expected, brand)
+		}
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	now := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	if err := card.CheckExpiry("03", "26", now); err != nil {
+		t.Errorf("Expected a card expiring this month to be valid, got %v", err)
+	}
+	if err := card.CheckExpiry("12", "2030", now); err != nil {
+		t.Errorf("Expected a four-digit year to be accepted, got %v", err)
+	}
+	if err := card.CheckExpiry("02", "2026", now); !errors.Is(err, card.ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+	if err := card.CheckExpiry("13", "2030", now); !errors.Is(err, card.ErrInvalidExpiry) {
+		t.Errorf("Expected ErrInvalidExpiry, got %v", err)
+	}
+	if err := card.CheckExpiry("12", "203", now); !errors.Is(err, card.ErrInvalidExpiry) {
+		t.Errorf("Expected ErrInvalidExpiry for a three-digit year, got %v", err)
+	}
+}
+
+func TestCheckCVV(t *testing.T) {
+	if err := card.CheckCVV("1234", card.BrandAmex); err != nil {
+		t.Errorf("Expected a 4-digit Amex CVV to be valid, got %v", err)
+	}
+	if err := card.CheckCVV("123", card.BrandAmex); err == nil {
+		t.Error("Expected a 3-digit Amex CVV to be invalid")
+	}
+	if err := card.CheckCVV("1234", card.BrandVisa); err == nil {
+		t.Error("Expected a 4-digit Visa CVV to be invalid")
+	}
+
+	errs := card.Validate("4111111111111112", "01", "2020", "12", time.Now())
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 problems, got %v", errs)
+	}
+}
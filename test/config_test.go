@@ -0,0 +1,55 @@
+package payment_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/streamerd/paytr-go/config"
+)
+
+func TestConfigLoadFileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paytr.json")
+	data := `{"merchant_id": "file_id", "merchant_key": "file_key", "merchant_salt": "file_salt"}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile returned an error: %v", err)
+	}
+	if cfg.MerchantID != "file_id" || cfg.MerchantKey != "file_key" || cfg.MerchantSalt != "file_salt" {
+		t.Errorf("unexpected config from file: %+v", cfg)
+	}
+
+	t.Setenv(config.EnvMerchantID, "env_id")
+	t.Setenv(config.EnvBaseURL, "http://localhost:8080")
+	cfg = cfg.FromEnv()
+	if cfg.MerchantID != "env_id" {
+		t.Errorf("expected the environment to override merchant_id, got %q", cfg.MerchantID)
+	}
+	if cfg.MerchantKey != "file_key" {
+		t.Errorf("expected merchant_key from the file to be kept, got %q", cfg.MerchantKey)
+	}
+	if cfg.BaseURL != "http://localhost:8080" {
+		t.Errorf("expected base URL from the environment, got %q", cfg.BaseURL)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate returned an error: %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	err := config.PayTRConfig{MerchantID: "id"}.Validate()
+	if err == nil {
+		t.Fatal("expected an error for missing credentials")
+	}
+	if !strings.Contains(err.Error(), "merchant_key") || !strings.Contains(err.Error(), "merchant_salt") {
+		t.Errorf("expected the missing fields to be named, got %q", err)
+	}
+	if strings.Contains(err.Error(), "merchant_id") {
+		t.Errorf("merchant_id is set and should not be reported, got %q", err)
+	}
+}
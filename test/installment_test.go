@@ -0,0 +1,152 @@
+package payment_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/installment"
+)
+
+func TestParseInstallmentRate(t *testing.T) {
+	cases := map[string]domain.InstallmentRate{
+		"3.21":   321,
+		"3,2":    320,
+		"12":     1200,
+		"0":      0,
+		"3.215":  322,
+		"3.2149": 321,
+		"1.000":  100,
+	}
+	for input, expected := range cases {
+		rate, err := domain.ParseInstallmentRate(input)
+		if err != nil {
+			t.Errorf("ParseInstallmentRate(%q) returned an error: %v", input, err)
+			continue
+		}
+		if rate != expected {
+			t.Errorf("ParseInstallmentRate(%q) = %d, expected %d", input, rate, expected)
+		}
+	}
+
+	for _, input := range []string{"", "3.", "-1", "1.000,00", "3.2x", "1234567"} {
+		if _, err := domain.ParseInstallmentRate(input); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestInstallmentOptions(t *testing.T) {
+	rates := domain.InstallmentRates{
+		Families: map[string]domain.InstallmentRateTable{
+			"world": {12: 1650, 3: 450, 6: 850},
+		},
+	}
+
+	options := installment.Options(domain.NewMoney(100000, "TL"), domain.BinDetails{CardFamily: "World", InstallmentAllowed: true}, rates)
+	if len(options) != 4 {
+		t.Fatalf("expected 4 options, got %d: %+v", len(options), options)
+	}
+
+	expected := []struct {
+		count          int
+		total, monthly int64
+	}{
+		{1, 100000, 100000},
+		{3, 104500, 34833},
+		{6, 108500, 18083},
+		{12, 116500, 9708},
+	}
+	for i, e := range expected {
+		o := options[i]
+		if o.Count != e.count || o.Total.Minor != e.total || o.Monthly.Minor != e.monthly {
+			t.Errorf("option %d = %d x %s (total %s), expected %d x %d (total %d)",
+				i, o.Count, o.Monthly, o.Total, e.count, e.monthly, e.total)
+		}
+		if o.Total.Currency != "TL" || o.Monthly.Currency != "TL" {
+			t.Errorf("option %d lost the currency: %+v", i, o)
+		}
+	}
+
+	if options := installment.Options(domain.NewMoney(100000, "TL"), domain.BinDetails{CardFamily: "none", InstallmentAllowed: true}, rates); len(options) != 1 {
+		t.Errorf("expected only the single payment for a family without rates, got %+v", options)
+	}
+
+	rates.MaxInstallmentsNonBusiness = 6
+	if options := installment.Options(domain.NewMoney(100000, "TL"), domain.BinDetails{CardFamily: "world", InstallmentAllowed: true}, rates); len(options) != 3 || options[2].Count != 6 {
+		t.Errorf("expected at most 6 installments for a personal card, got %+v", options)
+	}
+	if options := installment.Options(domain.NewMoney(100000, "TL"), domain.BinDetails{CardFamily: "world", BusinessCard: true, InstallmentAllowed: true}, rates); len(options) != 4 {
+		t.Errorf("expected 12 installments for a business card, got %+v", options)
+	}
+	if options := installment.Options(domain.NewMoney(100000, "TL"), domain.BinDetails{CardFamily: "world", BusinessCard: true}, rates); len(options) != 1 {
+		t.Errorf("expected only the single payment for a card that does not allow installments, got %+v", options)
+	}
+}
+
+func TestInstallmentCalculateRounding(t *testing.T) {
+	// 99.99 at 3.33% is 103.319667, charged as 103.32 and paid as 3 x 34.44.
+	o := installment.Calculate(domain.NewMoney(9999, "TL"), 3, 333)
+	if o.Total.String() != "103.32" {
+		t.Errorf("expected total 103.32, got %s", o.Total)
+	}
+	if o.Monthly.String() != "34.44" {
+		t.Errorf("expected monthly 34.44, got %s", o.Monthly)
+	}
+}
+
+func TestSimulatorInstallmentRates(t *testing.T) {
+	srv, svc := setupSimulator(t)
+	srv.SetInstallmentRates(domain.InstallmentRates{
+		MaxInstallmentsNonBusiness: 9,
+		Families: map[string]domain.InstallmentRateTable{
+			"bonus": {2: 199, 9: 1275},
+		},
+	})
+
+	rates, err := svc.GetInstallmentRates()
+	if err != nil {
+		t.Fatalf("GetInstallmentRates returned an error: %v", err)
+	}
+	if rates.MaxInstallmentsNonBusiness != 9 {
+		t.Errorf("expected max_inst_non_bus 9, got %d", rates.MaxInstallmentsNonBusiness)
+	}
+	if rates.RequestID == "" {
+		t.Error("expected the request ID to be echoed")
+	}
+	if got := rates.Families["bonus"][9]; got != 1275 {
+		t.Errorf("expected bonus 9 installment rate 12.75, got %s", got)
+	}
+
+	details, err := svc.GetBinDetails("555555")
+	if err != nil {
+		t.Fatalf("GetBinDetails returned an error: %v", err)
+	}
+	o := installment.Calculate(domain.NewMoney(50000, "TL"), 9, rates.Families[details.CardFamily][9])
+	if o.Total.String() != "563.75" {
+		t.Errorf("expected total 563.75, got %s", o.Total)
+	}
+}
+
+func TestGetInstallmentRatesPrecision(t *testing.T) {
+	testService := setupTestService(nil)
+	testService.SetHTTPClient(&mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Body: io.NopCloser(bytes.NewBufferString(
+					`{"status":"success","request_id":"1","max_inst_non_bus":"9","oranlar":{"world":{"taksit_2":"1.995","taksit_3":3.5}}}`)),
+			}, nil
+		},
+	})
+
+	rates, err := testService.GetInstallmentRates()
+	if err != nil {
+		t.Fatalf("GetInstallmentRates returned an error: %v", err)
+	}
+	if rates.Families["world"][2] != 200 || rates.Families["world"][3] != 350 {
+		t.Errorf("expected rates 2.00 and 3.50, got %v", rates.Families["world"])
+	}
+}
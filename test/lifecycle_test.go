@@ -0,0 +1,196 @@
+package payment_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+	"github.com/streamerd/paytr-go/store"
+)
+
+func TestPaymentTransitions(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := domain.NewPayment("order1", domain.NewMoney(10000, "TL"), start)
+
+	steps := []struct {
+		to      domain.PaymentStatus
+		allowed bool
+	}{
+		{domain.PaymentStatusRefunded, false},
+		{domain.PaymentStatusAwaiting3DS, true},
+		{domain.PaymentStatusCreated, false},
+		{domain.PaymentStatusSucceeded, true},
+		{domain.PaymentStatusFailed, false},
+		{domain.PaymentStatusExpired, false},
+	}
+	for i, step := range steps {
+		err := p.Transition(step.to, start.Add(time.Duration(i+1)*time.Minute), "")
+		if step.allowed && err != nil {
+			t.Errorf("Expected move to %s to be allowed, got %v", step.to, err)
+		}
+		if !step.allowed && !errors.Is(err, domain.ErrIllegalTransition) {
+			t.Errorf("Expected move to %s to be illegal, got %v", step.to, err)
+		}
+	}
+
+	if p.Status != domain.PaymentStatusSucceeded {
+		t.Errorf("Expected status 'succeeded', got '%s'", p.Status)
+	}
+	if len(p.History) != 3 {
+		t.Errorf("Expected 3 status changes, got %+v", p.History)
+	}
+	if at, ok := p.StatusAt(domain.PaymentStatusSucceeded); !ok || !at.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("Unexpected time of success: %v %v", at, ok)
+	}
+
+	if err := p.Refund(domain.NewMoney(4000, "TL"), start, ""); err != nil || p.Status != domain.PaymentStatusPartiallyRefunded {
+		t.Errorf("Expected a partial refund, got %v %s", err, p.Status)
+	}
+	if err := p.Refund(domain.NewMoney(6000, "TL"), start, ""); err != nil || p.Status != domain.PaymentStatusRefunded {
+		t.Errorf("Expected a full refund, got %v %s", err, p.Status)
+	}
+	if !p.Status.IsFinal() || p.RefundedAmount.Minor != 10000 {
+		t.Errorf("Unexpected refunded payment: %+v", p)
+	}
+
+	// A refund recorded after the full refund gets its own entry
+	if err := p.Refund(domain.NewMoney(500, "TL"), start, ""); err != nil {
+		t.Errorf("Expected a refund of a refunded payment to be recorded, got %v", err)
+	}
+	refunds := p.Refunds()
+	if len(refunds) != 3 || refunds[1].Amount.Minor != 6000 || refunds[2].Amount.Minor != 500 || refunds[2].From != domain.PaymentStatusRefunded {
+		t.Errorf("Expected three refunds of 40.00, 60.00 and 5.00, got %+v", refunds)
+	}
+
+	if err := p.Refund(domain.NewMoney(500, "USD"), start, ""); !errors.Is(err, domain.ErrCurrencyMismatch) || len(p.Refunds()) != 3 {
+		t.Errorf("Expected a refund in another currency to be rejected, got %v", err)
+	}
+}
+
+// racingRepository stores a concurrent refund of 10.00 right before the first
+// payment it is asked to save, as a second process would
+type racingRepository struct {
+	*store.Memory
+	raced bool
+}
+
+func (r *racingRepository) SavePayment(ctx context.Context, p *domain.Payment) error {
+	if !r.raced && p.Version != 0 {
+		r.raced = true
+		other, _ := r.FindPayment(ctx, p.MerchantOid)
+		other.Refund(domain.NewMoney(1000, "TL"), time.Now(), "refund elsewhere")
+		r.Memory.SavePayment(ctx, other)
+	}
+	return r.Memory.SavePayment(ctx, p)
+}
+
+func TestServiceRetriesConflictingUpdates(t *testing.T) {
+	ctx := context.Background()
+	_, svc := setupSimulator(t)
+	if _, err := svc.NewCardPayment(simulatorPayment("order1", 10000)); err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+
+	repo := store.NewMemory()
+	p := domain.NewPayment("order1", domain.NewMoney(10000, "TL"), time.Now())
+	p.Transition(domain.PaymentStatusSucceeded, time.Now(), "")
+	repo.SavePayment(ctx, p)
+	racing := &racingRepository{Memory: repo}
+	svc.SetPaymentRepository(racing)
+
+	if _, err := svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(2500, "TL")}); err != nil {
+		t.Fatalf("RefundPayment returned an error: %v", err)
+	}
+
+	p, _ = repo.FindPayment(ctx, "order1")
+	if !racing.raced || len(p.Refunds()) != 2 || p.RefundedAmount.Minor != 3500 {
+		t.Errorf("Expected both refunds to be recorded, got %s %+v", p.RefundedAmount, p.Refunds())
+	}
+}
+
+func TestServiceDrivesPaymentLifecycle(t *testing.T) {
+	ctx := context.Background()
+	srv, svc := setupSimulator(t)
+	repo := store.NewMemory()
+	svc.SetPaymentRepository(repo)
+
+	callback := httptest.NewServer(payment.NewCallbackHandler(srv.Config(), func(n domain.PaymentNotification) error {
+		return svc.ApplyNotification(n)
+	}))
+	defer callback.Close()
+
+	// The notification arrives before the response, as if the customer completed 3D Secure
+	srv.NotifyURL = callback.URL
+	if _, err := svc.NewCardPayment(simulatorPayment("order1", 10000)); err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+	p, _ := repo.FindPayment(ctx, "order1")
+	if p.Status != domain.PaymentStatusSucceeded {
+		t.Errorf("Expected status 'succeeded', got '%s' (%+v)", p.Status, p.History)
+	}
+
+	// Without a notification the payment waits for 3D Secure, until it expires
+	srv.NotifyURL = ""
+	if _, err := svc.NewCardPayment(simulatorPayment("order2", 10000)); err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+	if p, _ := repo.FindPayment(ctx, "order2"); p.Status != domain.PaymentStatusAwaiting3DS {
+		t.Errorf("Expected status 'awaiting_3ds', got '%s'", p.Status)
+	}
+	if err := svc.ExpirePayment("order2"); err != nil {
+		t.Fatalf("ExpirePayment returned an error: %v", err)
+	}
+	if err := svc.ExpirePayment("order1"); !errors.Is(err, domain.ErrIllegalTransition) {
+		t.Errorf("Expected a succeeded payment not to expire, got %v", err)
+	}
+
+	// Reusing a merchant_oid is rejected before anything is sent
+	_, err := svc.NewCardPayment(simulatorPayment("order1", 10000))
+	var paytrErr *payment.Error
+	if !errors.As(err, &paytrErr) || paytrErr.Kind != payment.ErrorKindValidation || !errors.Is(err, domain.ErrIllegalTransition) {
+		t.Errorf("Expected an illegal transition error, got %v", err)
+	}
+
+	// A failed payment cannot be refunded
+	srv.Decline("4111111111111111", "Insufficient funds")
+	svc.NewCardPayment(simulatorPayment("order3", 10000))
+	_, err = svc.RefundPayment(domain.RefundRequest{MerchantOid: "order3", ReturnAmount: domain.NewMoney(100, "TL")})
+	if !errors.Is(err, domain.ErrIllegalTransition) {
+		t.Errorf("Expected refunding a failed payment to be rejected, got %v", err)
+	}
+	if order, _ := srv.Order("order3"); len(order.Refunds) != 0 {
+		t.Errorf("Expected the refund not to be sent, got %+v", order.Refunds)
+	}
+
+	// A refund in another currency is rejected from the record, even without the refund check
+	svc.SetRefundCheck(false)
+	_, err = svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(2500, "USD")})
+	var validationErr *payment.ValidationError
+	if !errors.As(err, &validationErr) || !validationErr.Has("return_amount") {
+		t.Errorf("Expected a return_amount error, got %v", err)
+	}
+	if order, _ := srv.Order("order1"); len(order.Refunds) != 0 {
+		t.Errorf("Expected the refund not to be sent, got %+v", order.Refunds)
+	}
+	svc.SetRefundCheck(true)
+
+	if _, err := svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(2500, "TL")}); err != nil {
+		t.Fatalf("RefundPayment returned an error: %v", err)
+	}
+	p, _ = repo.FindPayment(ctx, "order1")
+	if p.Status != domain.PaymentStatusPartiallyRefunded || p.RefundedAmount.Minor != 2500 {
+		t.Errorf("Expected a partially refunded payment, got %s %v", p.Status, p.RefundedAmount)
+	}
+
+	// A status inquiry still reports success after a refund
+	if _, err := svc.MerchantStatusInquiry(domain.StatusInquiryRequest{MerchantOid: "order1"}); err != nil {
+		t.Fatalf("MerchantStatusInquiry returned an error: %v", err)
+	}
+	if p, _ := repo.FindPayment(ctx, "order1"); p.Status != domain.PaymentStatusPartiallyRefunded {
+		t.Errorf("Expected status 'partially_refunded', got '%s'", p.Status)
+	}
+}
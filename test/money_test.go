@@ -0,0 +1,97 @@
+package payment_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]int64{
+		"100":       10000,
+		"100.5":     10050,
+		"100.50":    10050,
+		"100,50":    10050,
+		"1.234,56":  123456,
+		"1,234.56":  123456,
+		"1.234.567": 123456700,
+		"0.10":      10,
+		"-12.30":    -1230,
+		"99,90":     9990,
+		"1.000,00":  100000,
+		" 1234.56 ": 123456,
+	}
+
+	for input, expected := range cases {
+		m, err := domain.ParseMoney(input, "TRY")
+		if err != nil {
+			t.Errorf("ParseMoney(%q) returned an error: %v", input, err)
+			continue
+		}
+		if m.Minor != expected {
+			t.Errorf("ParseMoney(%q): expected %d minor units, got %d", input, expected, m.Minor)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1.234.5x", "10.999", "1.234", "1.000", "1,000", "99.900", "1.+5", "+5", "1.-5", "92233720368547758.08"} {
+		if _, err := domain.ParseMoney(input, "TRY"); err == nil {
+			t.Errorf("ParseMoney(%q): expected an error", input)
+		}
+	}
+}
+
+func TestMoneyFormatting(t *testing.T) {
+	m := domain.NewMoney(10050, "TRY")
+
+	if m.String() != "100.50" {
+		t.Errorf("Expected '100.50', got '%s'", m.String())
+	}
+	if m.MinorString() != "10050" {
+		t.Errorf("Expected '10050', got '%s'", m.MinorString())
+	}
+	if domain.NewMoney(-5, "TRY").String() != "-0.05" {
+		t.Errorf("Expected '-0.05', got '%s'", domain.NewMoney(-5, "TRY").String())
+	}
+
+	var decoded struct {
+		Amount domain.Money `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount": 19.99}`), &decoded); err != nil {
+		t.Fatalf("Unmarshal returned an error: %v", err)
+	}
+	if decoded.Amount.Minor != 1999 {
+		t.Errorf("Expected 1999 minor units, got %d", decoded.Amount.Minor)
+	}
+}
+
+func TestStatusInquiryResponseAmount(t *testing.T) {
+	resp := domain.StatusInquiryResponse{PaymentAmount: "100.00", PaymentTotal: "104.50", Currency: "TL"}
+
+	amount, err := resp.Amount()
+	if err != nil || amount.Minor != 10000 || amount.Currency != "TL" {
+		t.Errorf("Unexpected amount %+v (err: %v)", amount, err)
+	}
+
+	total, err := resp.Total()
+	if err != nil || total.Minor != 10450 {
+		t.Errorf("Unexpected total %+v (err: %v)", total, err)
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	tl, try := domain.NewMoney(100, "TL"), domain.NewMoney(50, "TRY")
+	if sum := tl.Add(try); sum.Minor != 150 || sum.Currency != "TL" {
+		t.Errorf("Expected TL and TRY to add up, got %+v", sum)
+	}
+	if sum := (domain.Money{}).Add(tl); sum.Currency != "TL" {
+		t.Errorf("Expected an unset currency to take the other one, got %+v", sum)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected adding USD to TL to panic")
+		}
+	}()
+	tl.Add(domain.NewMoney(100, "USD"))
+}
@@ -2,10 +2,19 @@ package payment_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/streamerd/paytr-go/config"
 	"github.com/streamerd/paytr-go/domain"
@@ -27,6 +36,9 @@ func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 }
 
 // setupTestService creates a test service with a mock HTTP client
+// testExpiryYear is a card expiry year that stays in the future.
+var testExpiryYear = strconv.Itoa(time.Now().Year() + 5)
+
 func setupTestService(mockResponse *domain.PayTRResponse) payment.Service {
 	mockClient := &mockHTTPClient{
 		DoFunc: func(req *http.Request) (*http.Response, error) {
@@ -63,9 +75,10 @@ func TestNewCardPayment(t *testing.T) {
 		CommonPaymentRequest: domain.CommonPaymentRequest{
 			MerchantID:    "test_merchant",
 			UserIP:        "127.0.0.1",
-			MerchantOid:   "test_order_123",
+			MerchantOid:   "testorder123",
 			Email:         "test@example.com",
-			PaymentAmount: 100.00,
+			PaymentAmount: domain.NewMoney(10000, "TRY"),
+			UserBasket:    domain.NewBasket().Add("Product", domain.NewMoney(10000, "TRY"), 1),
 			PaymentType:   "card",
 			Currency:      "TRY",
 			TestMode:      "1",
@@ -73,7 +86,7 @@ func TestNewCardPayment(t *testing.T) {
 		CardOwner:   "John Doe",
 		CardNumber:  "4111111111111111",
 		ExpiryMonth: "12",
-		ExpiryYear:  "2025",
+		ExpiryYear:  testExpiryYear,
 		CVV:         "123",
 	}
 
@@ -100,9 +113,10 @@ func TestSavedCardPayment(t *testing.T) {
 		CommonPaymentRequest: domain.CommonPaymentRequest{
 			MerchantID:    "test_merchant",
 			UserIP:        "127.0.0.1",
-			MerchantOid:   "test_order_456",
+			MerchantOid:   "testorder456",
 			Email:         "test@example.com",
-			PaymentAmount: 200.00,
+			PaymentAmount: domain.NewMoney(20000, "TRY"),
+			UserBasket:    domain.NewBasket().Add("Product", domain.NewMoney(20000, "TRY"), 1),
 			PaymentType:   "card",
 			Currency:      "TRY",
 			TestMode:      "1",
@@ -135,9 +149,10 @@ func TestRecurringPayment(t *testing.T) {
 		CommonPaymentRequest: domain.CommonPaymentRequest{
 			MerchantID:    "test_merchant",
 			UserIP:        "127.0.0.1",
-			MerchantOid:   "test_order_789",
+			MerchantOid:   "testorder789",
 			Email:         "test@example.com",
-			PaymentAmount: 50.00,
+			PaymentAmount: domain.NewMoney(5000, "TRY"),
+			UserBasket:    domain.NewBasket().Add("Product", domain.NewMoney(5000, "TRY"), 1),
 			PaymentType:   "card",
 			Currency:      "TRY",
 			TestMode:      "1",
@@ -166,10 +181,12 @@ func TestRefundPayment(t *testing.T) {
 	}
 
 	testService := setupTestService(mockResponse)
+	// The mock answers every request alike, so refunds are sent without the status inquiry.
+	testService.SetRefundCheck(false)
 
 	req := domain.RefundRequest{
-		MerchantOid:  "test_order_789",
-		ReturnAmount: 50.00,
+		MerchantOid:  "testorder789",
+		ReturnAmount: domain.NewMoney(5000, "TRY"),
 	}
 
 	resp, err := testService.RefundPayment(req)
@@ -183,80 +200,80 @@ func TestRefundPayment(t *testing.T) {
 	}
 }
 
-// func TestGetTransactionDetails(t *testing.T) {
-// 	mockResponse := &domain.PayTRResponse{
-// 		Status:  "success",
-// 		Message: "Transaction details retrieved",
-// 		Data: map[string]interface{}{
-// 			"status": "success",
-// 			"transactions": []map[string]interface{}{
-// 				{
-// 					"islem_tipi":     "sale",
-// 					"net_tutar":      "95.00",
-// 					"kesinti_tutari": "5.00",
-// 					"kesinti_orani":  "5",
-// 					"islem_tutari":   "100.00",
-// 					"odeme_tutari":   "100.00",
-// 					"islem_tarihi":   "2023-01-01 12:00:00",
-// 					"para_birimi":    "TRY",
-// 					"taksit":         "1",
-// 					"kart_marka":     "VISA",
-// 					"kart_no":        "411111******1111",
-// 					"siparis_no":     "test_order_123",
-// 					"odeme_tipi":     "card",
-// 				},
-// 			},
-// 		},
-// 	}
-
-// 	testService := setupTestService(mockResponse)
-
-// 	req := domain.TransactionDetailsRequest{
-// 		StartDate: "2023-01-01",
-// 		EndDate:   "2023-12-31",
-// 	}
-
-// 	resp, err := testService.GetTransactionDetails(req)
-// 	if err != nil {
-// 		t.Fatalf("GetTransactionDetails returned an error: %v", err)
-// 	}
-
-// 	t.Logf("Response: %+v", resp)
-
-// 	if resp.Status != "success" {
-// 		t.Errorf("Expected status 'success', got '%s'", resp.Status)
-// 	}
-
-// 	if len(resp.Transactions) != 1 {
-// 		t.Fatalf("Expected 1 transaction, got %d", len(resp.Transactions))
-// 	}
-
-// 	transaction := resp.Transactions[0]
-// 	t.Logf("Transaction: %+v", transaction)
-
-// 	expectedFields := map[string]string{
-// 		"IslemTipi":     "sale",
-// 		"NetTutar":      "95.00",
-// 		"KesintiTutari": "5.00",
-// 		"KesintiOrani":  "5",
-// 		"IslemTutari":   "100.00",
-// 		"OdemeTutari":   "100.00",
-// 		"IslemTarihi":   "2023-01-01 12:00:00",
-// 		"ParaBirimi":    "TRY",
-// 		"Taksit":        "1",
-// 		"KartMarka":     "VISA",
-// 		"KartNo":        "411111******1111",
-// 		"SiparisNo":     "test_order_123",
-// 		"OdemeTipi":     "card",
-// 	}
-
-// 	for field, expected := range expectedFields {
-// 		actual := reflect.ValueOf(transaction).FieldByName(field).String()
-// 		if actual != expected {
-// 			t.Errorf("Expected %s '%s', got '%s'", field, expected, actual)
-// 		}
-// 	}
-// }
+func TestGetTransactionDetails(t *testing.T) {
+	mockResponse := &domain.PayTRResponse{
+		Status:  "success",
+		Message: "Transaction details retrieved",
+		Data: map[string]interface{}{
+			"status": "success",
+			"transactions": []map[string]interface{}{
+				{
+					"islem_tipi":     "sale",
+					"net_tutar":      "95.00",
+					"kesinti_tutari": "5.00",
+					"kesinti_orani":  "5",
+					"islem_tutari":   "100.00",
+					"odeme_tutari":   "100.00",
+					"islem_tarihi":   "2023-01-01 12:00:00",
+					"para_birimi":    "TRY",
+					"taksit":         "1",
+					"kart_marka":     "VISA",
+					"kart_no":        "411111******1111",
+					"siparis_no":     "test_order_123",
+					"odeme_tipi":     "card",
+				},
+			},
+		},
+	}
+
+	testService := setupTestService(mockResponse)
+
+	req := domain.TransactionDetailsRequest{
+		StartDate: "2023-01-01",
+		EndDate:   "2023-12-31",
+	}
+
+	resp, err := testService.GetTransactionDetails(req)
+	if err != nil {
+		t.Fatalf("GetTransactionDetails returned an error: %v", err)
+	}
+
+	t.Logf("Response: %+v", resp)
+
+	if resp.Status != "success" {
+		t.Errorf("Expected status 'success', got '%s'", resp.Status)
+	}
+
+	if len(resp.Transactions) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(resp.Transactions))
+	}
+
+	transaction := resp.Transactions[0]
+	t.Logf("Transaction: %+v", transaction)
+
+	expectedFields := map[string]string{
+		"IslemTipi":     "sale",
+		"NetTutar":      "95.00",
+		"KesintiTutari": "5.00",
+		"KesintiOrani":  "5",
+		"IslemTutari":   "100.00",
+		"OdemeTutari":   "100.00",
+		"IslemTarihi":   "2023-01-01 12:00:00",
+		"ParaBirimi":    "TRY",
+		"Taksit":        "1",
+		"KartMarka":     "VISA",
+		"KartNo":        "411111******1111",
+		"SiparisNo":     "test_order_123",
+		"OdemeTipi":     "card",
+	}
+
+	for field, expected := range expectedFields {
+		actual := reflect.ValueOf(transaction).FieldByName(field).String()
+		if actual != expected {
+			t.Errorf("Expected %s '%s', got '%s'", field, expected, actual)
+		}
+	}
+}
 
 func TestMerchantStatusInquiry(t *testing.T) {
 	mockResponse := &domain.PayTRResponse{
@@ -265,6 +282,7 @@ func TestMerchantStatusInquiry(t *testing.T) {
 		Data: map[string]interface{}{
 			"status":         "success",
 			"payment_amount": "100.00",
+			"payment_total":  100.5,
 			"currency":       "TRY",
 		},
 	}
@@ -284,6 +302,11 @@ func TestMerchantStatusInquiry(t *testing.T) {
 	if resp.Status != "success" {
 		t.Errorf("Expected status 'success', got '%s'", resp.Status)
 	}
+
+	// Fields are matched by their json tags and numbers are accepted for string fields.
+	if resp.PaymentAmount != "100.00" || resp.PaymentTotal != "100.5" || resp.Currency != "TRY" {
+		t.Errorf("Unexpected decoded response: %+v", resp)
+	}
 }
 
 func TestAddNewCard(t *testing.T) {
@@ -299,10 +322,13 @@ func TestAddNewCard(t *testing.T) {
 
 	req := domain.AddNewCardRequest{
 		UserID:      "test_user",
+		UserIP:      "127.0.0.1",
+		MerchantOid: "testorder999",
+		Email:       "test@example.com",
 		CardOwner:   "John Doe",
 		CardNumber:  "4111111111111111",
 		ExpiryMonth: "12",
-		ExpiryYear:  "2025",
+		ExpiryYear:  testExpiryYear,
 		CVV:         "123",
 	}
 
@@ -324,9 +350,16 @@ func TestGetSavedCards(t *testing.T) {
 		Data: map[string]interface{}{
 			"cards": []map[string]interface{}{
 				{
-					"token":     "card_token_1",
-					"last_four": "1111",
-					"card_type": "visa",
+					"ctoken":      "card_token_1",
+					"last_4":      "1111",
+					"month":       "1",
+					"year":        "2030",
+					"c_bank":      "Test Bank",
+					"c_name":      "John Doe",
+					"c_brand":     "world",
+					"c_type":      "credit",
+					"require_cvv": "1",
+					"schema":      "VISA",
 				},
 			},
 		},
@@ -334,14 +367,32 @@ func TestGetSavedCards(t *testing.T) {
 
 	testService := setupTestService(mockResponse)
 
-	resp, err := testService.GetSavedCards("test_user_token")
+	cards, err := testService.GetSavedCards("test_user_token")
 
 	if err != nil {
 		t.Fatalf("GetSavedCards returned an error: %v", err)
 	}
 
-	if resp.Status != "success" {
-		t.Errorf("Expected status 'success', got '%s'", resp.Status)
+	if len(cards) != 1 {
+		t.Fatalf("Expected 1 card, got %d", len(cards))
+	}
+
+	expected := domain.SavedCard{
+		UToken:     "test_user_token",
+		CToken:     "card_token_1",
+		LastFour:   "1111",
+		CardType:   "credit",
+		ExpiryDate: "01/30",
+		Month:      "1",
+		Year:       "2030",
+		Bank:       "Test Bank",
+		OwnerName:  "John Doe",
+		Brand:      "world",
+		Schema:     "VISA",
+		RequireCVV: true,
+	}
+	if !reflect.DeepEqual(cards[0], expected) {
+		t.Errorf("Expected card %+v, got %+v", expected, cards[0])
 	}
 }
 
@@ -350,8 +401,13 @@ func TestGetBinDetails(t *testing.T) {
 		Status:  "success",
 		Message: "BIN details retrieved",
 		Data: map[string]interface{}{
-			"bin_brand": "VISA",
-			"bin_type":  "CREDIT",
+			"bank":         "Test Bank",
+			"schema":       "VISA",
+			"cardType":     "credit",
+			"brand":        "world",
+			"businessCard": "n",
+			"allow_non3d":  "Y",
+			"installment":  "Y",
 		},
 	}
 
@@ -363,12 +419,67 @@ func TestGetBinDetails(t *testing.T) {
 		t.Fatalf("GetBinDetails returned an error: %v", err)
 	}
 
-	if resp.Status != "success" {
-		t.Errorf("Expected status 'success', got '%s'", resp.Status)
+	if resp.Brand != "VISA" {
+		t.Errorf("Expected brand 'VISA', got '%s'", resp.Brand)
+	}
+
+	if !resp.IsCredit() || resp.CardFamily != "world" || resp.Bank != "Test Bank" {
+		t.Errorf("Unexpected BIN details: %+v", resp)
 	}
 
-	if resp.Data["bin_brand"] != "VISA" {
-		t.Errorf("Expected bin_brand 'VISA', got '%s'", resp.Data["bin_brand"])
+	if resp.BusinessCard || !resp.AllowNon3D || !resp.InstallmentAllowed {
+		t.Errorf("Unexpected BIN flags: %+v", resp)
+	}
+}
+
+func TestGetBinDetailsCache(t *testing.T) {
+	calls := 0
+	mockClient := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls++
+			req.ParseForm()
+			responseBody, _ := json.Marshal(domain.PayTRResponse{
+				Status: "success",
+				Data:   map[string]interface{}{"schema": "VISA", "bin": req.PostForm.Get("bin_number")},
+			})
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			}, nil
+		},
+	}
+
+	testService := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+	})
+	testService.SetHTTPClient(mockClient)
+	testService.SetBinCache(2, time.Minute)
+
+	for _, bin := range []string{"411111", "411111", "555555", "411111"} {
+		if _, err := testService.GetBinDetails(bin); err != nil {
+			t.Fatalf("GetBinDetails returned an error: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 requests, got %d", calls)
+	}
+
+	// A third BIN evicts the least recently used one, 555555
+	testService.GetBinDetails("979200")
+	testService.GetBinDetails("411111")
+	testService.GetBinDetails("555555")
+	if calls != 4 {
+		t.Errorf("Expected 4 requests, got %d", calls)
+	}
+
+	testService.SetBinCache(2, time.Nanosecond)
+	testService.GetBinDetails("411111")
+	time.Sleep(time.Millisecond)
+	testService.GetBinDetails("411111")
+	if calls != 6 {
+		t.Errorf("Expected expired entries to be fetched again, got %d requests", calls)
 	}
 }
 
@@ -390,3 +501,498 @@ func TestDeleteSavedCard(t *testing.T) {
 		t.Errorf("Expected status 'success', got '%s'", resp.Status)
 	}
 }
+
+func TestIFrameToken(t *testing.T) {
+	var sent url.Values
+	mockClient := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			req.ParseForm()
+			sent = req.PostForm
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"status":"success","token":"iframe_token"}`)),
+			}, nil
+		},
+	}
+
+	testService := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+	})
+	testService.SetHTTPClient(mockClient)
+
+	req := domain.IFrameTokenRequest{
+		UserIP:         "127.0.0.1",
+		MerchantOid:    "testorder123",
+		Email:          "test@example.com",
+		PaymentAmount:  domain.NewMoney(9990, "TL"),
+		Currency:       "TL",
+		UserBasket:     domain.NewBasket().Add("Product", domain.NewMoney(9990, "TL"), 1),
+		NoInstallment:  "0",
+		MaxInstallment: "0",
+		TestMode:       "1",
+	}
+
+	resp, err := testService.IFrameToken(req)
+	if err != nil {
+		t.Fatalf("IFrameToken returned an error: %v", err)
+	}
+
+	if resp.Token != "iframe_token" {
+		t.Errorf("Expected token 'iframe_token', got '%s'", resp.Token)
+	}
+
+	if resp.IFrameURL != domain.PayTRIFrameURL+"iframe_token" {
+		t.Errorf("Unexpected iframe URL '%s'", resp.IFrameURL)
+	}
+
+	if sent.Get("payment_amount") != "9990" {
+		t.Errorf("Expected payment_amount '9990', got '%s'", sent.Get("payment_amount"))
+	}
+
+	if sent.Get("user_basket") != base64.StdEncoding.EncodeToString([]byte(`[["Product","99.90",1]]`)) {
+		t.Errorf("Expected base64 encoded user_basket, got '%s'", sent.Get("user_basket"))
+	}
+}
+
+func TestMerchantStatusInquiryContextCancelled(t *testing.T) {
+	mockClient := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		},
+	}
+
+	testService := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+	})
+	testService.SetHTTPClient(mockClient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := testService.MerchantStatusInquiryContext(ctx, domain.StatusInquiryRequest{MerchantOid: "testorder123"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte(`{"status":"success","payment_amount":"100.00"}`))
+	}))
+	defer server.Close()
+
+	testService := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+		BaseURL:      server.URL,
+		Timeout:      20 * time.Millisecond,
+	})
+	testService.SetRetryPolicy(payment.RetryPolicy{MaxAttempts: 1})
+
+	_, err := testService.MerchantStatusInquiry(domain.StatusInquiryRequest{MerchantOid: "testorder123"})
+	var paytrErr *payment.Error
+	if !errors.As(err, &paytrErr) || paytrErr.Kind != payment.ErrorKindRetryable {
+		t.Errorf("Expected the configured timeout to apply without a deadline, got %v", err)
+	}
+
+	// A longer context deadline replaces the configured timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := testService.MerchantStatusInquiryContext(ctx, domain.StatusInquiryRequest{MerchantOid: "testorder123"}); err != nil {
+		t.Errorf("Expected the context deadline to be honoured, got %v", err)
+	}
+
+	if timeout := (config.PayTRConfig{}).RequestTimeout(); timeout != config.DefaultTimeout {
+		t.Errorf("Expected the default timeout, got %v", timeout)
+	}
+}
+
+func TestRequestEncoding(t *testing.T) {
+	var contentType string
+	var sent url.Values
+	mockClient := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			contentType = req.Header.Get("Content-Type")
+			req.ParseForm()
+			sent = req.PostForm
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"status":"success"}`)),
+			}, nil
+		},
+	}
+
+	testService := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+	})
+	testService.SetHTTPClient(mockClient)
+
+	_, err := testService.SavedCardPayment(domain.SavedCardPaymentRequest{
+		CommonPaymentRequest: domain.CommonPaymentRequest{
+			MerchantID:    "test_merchant",
+			UserIP:        "127.0.0.1",
+			MerchantOid:   "testorder456",
+			Email:         "test@example.com",
+			PaymentAmount: domain.NewMoney(20000, "TRY"),
+			UserBasket:    domain.NewBasket().Add("Product", domain.NewMoney(20000, "TRY"), 1),
+			PaymentType:   "card",
+			Currency:      "TRY",
+		},
+		UToken: "test_utoken",
+		CToken: "test_ctoken",
+	})
+	if err != nil {
+		t.Fatalf("SavedCardPayment returned an error: %v", err)
+	}
+
+	if contentType != "application/x-www-form-urlencoded" {
+		t.Errorf("Expected form content type, got '%s'", contentType)
+	}
+
+	expected := map[string]string{
+		"merchant_id":    "test_merchant",
+		"merchant_oid":   "testorder456",
+		"payment_amount": "200.00",
+		"utoken":         "test_utoken",
+		"ctoken":         "test_ctoken",
+	}
+	for field, value := range expected {
+		if sent.Get(field) != value {
+			t.Errorf("Expected %s '%s', got '%s'", field, value, sent.Get(field))
+		}
+	}
+
+	if sent.Get("paytr_token") == "" {
+		t.Error("Expected paytr_token to be sent")
+	}
+
+	testService.SetRequestEncoder(payment.JSONEncoder)
+	if _, err := testService.GetSavedCards("test_utoken"); err != nil {
+		t.Fatalf("GetSavedCards returned an error: %v", err)
+	}
+
+	if contentType != "application/json" {
+		t.Errorf("Expected JSON content type, got '%s'", contentType)
+	}
+}
+
+func TestPaymentUsesConfiguredMerchantID(t *testing.T) {
+	var sent []url.Values
+	testService := setupTestService(nil)
+	testService.SetHTTPClient(&mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			req.ParseForm()
+			sent = append(sent, req.PostForm)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"status":"success"}`)),
+			}, nil
+		},
+	})
+
+	common := domain.CommonPaymentRequest{
+		MerchantID:    "other_merchant",
+		UserIP:        "127.0.0.1",
+		MerchantOid:   "testorder456",
+		Email:         "test@example.com",
+		PaymentAmount: domain.NewMoney(20000, "TRY"),
+		UserBasket:    domain.NewBasket().Add("Product", domain.NewMoney(20000, "TRY"), 1),
+		PaymentType:   "card",
+		Currency:      "TRY",
+	}
+	saved := domain.SavedCardPaymentRequest{CommonPaymentRequest: common, UToken: "test_utoken", CToken: "test_ctoken"}
+	if _, err := testService.SavedCardPayment(saved); err != nil {
+		t.Fatalf("SavedCardPayment returned an error: %v", err)
+	}
+	if _, err := testService.RecurringPayment(saved); err != nil {
+		t.Fatalf("RecurringPayment returned an error: %v", err)
+	}
+	common.MerchantID = ""
+	if _, err := testService.NewCardPayment(domain.NewCardPaymentRequest{
+		CommonPaymentRequest: common,
+		CardOwner:            "John Doe",
+		CardNumber:           "4111111111111111",
+		ExpiryMonth:          "12",
+		ExpiryYear:           testExpiryYear,
+		CVV:                  "123",
+	}); err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+
+	if len(sent) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(sent))
+	}
+	for i, form := range sent {
+		if form.Get("merchant_id") != "test_merchant" {
+			t.Errorf("Request %d: expected merchant_id 'test_merchant', got '%s'", i, form.Get("merchant_id"))
+		}
+	}
+}
+
+func TestEndpointConfiguration(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer server.Close()
+
+	testService := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+		BaseURL:      server.URL + "/",
+		Endpoints: map[domain.Endpoint]string{
+			domain.EndpointRefund: "/proxy/paytr/refund",
+		},
+	})
+	testService.SetRefundCheck(false)
+
+	if _, err := testService.GetSavedCards("test_utoken"); err != nil {
+		t.Fatalf("GetSavedCards returned an error: %v", err)
+	}
+	if _, err := testService.RefundPayment(domain.RefundRequest{
+		MerchantOid:  "testorder789",
+		ReturnAmount: domain.NewMoney(5000, "TRY"),
+	}); err != nil {
+		t.Fatalf("RefundPayment returned an error: %v", err)
+	}
+
+	expected := []string{"/odeme/capi/list", "/proxy/paytr/refund"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected requests to %v, got %v", expected, paths)
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	testService := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+	})
+	testService.SetRefundCheck(false)
+
+	cases := []struct {
+		name string
+		do   func(req *http.Request) (*http.Response, error)
+		kind payment.ErrorKind
+	}{
+		{
+			name: "server error",
+			do: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 503, Body: io.NopCloser(bytes.NewBufferString("unavailable"))}, nil
+			},
+			kind: payment.ErrorKindRetryable,
+		},
+		{
+			name: "connection reset",
+			do: func(req *http.Request) (*http.Response, error) {
+				return nil, syscall.ECONNRESET
+			},
+			kind: payment.ErrorKindRetryable,
+		},
+		{
+			name: "known error code",
+			do: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(`{"status":"failed","err_no":"2"}`))}, nil
+			},
+			kind: payment.ErrorKindDeclined,
+		},
+		{
+			name: "invalid token",
+			do: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(`{"status":"failed","reason":"paytr_token gecersiz"}`))}, nil
+			},
+			kind: payment.ErrorKindAuth,
+		},
+	}
+
+	for _, tc := range cases {
+		testService.SetHTTPClient(&mockHTTPClient{DoFunc: tc.do})
+
+		_, err := testService.RefundPayment(domain.RefundRequest{
+			MerchantOid:  "testorder789",
+			ReturnAmount: domain.NewMoney(5000, "TRY"),
+		})
+
+		var paytrErr *payment.Error
+		if !errors.As(err, &paytrErr) {
+			t.Errorf("%s: expected *payment.Error, got %v", tc.name, err)
+			continue
+		}
+		if paytrErr.Kind != tc.kind {
+			t.Errorf("%s: expected kind %s, got %s", tc.name, tc.kind, paytrErr.Kind)
+		}
+		if paytrErr.Endpoint != domain.EndpointRefund {
+			t.Errorf("%s: expected endpoint %s, got %s", tc.name, domain.EndpointRefund, paytrErr.Endpoint)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	calls := 0
+	mockClient := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls < 3 {
+				return &http.Response{StatusCode: 502, Body: io.NopCloser(bytes.NewBufferString("bad gateway"))}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"status":"success","data":{"status":"success"}}`)),
+			}, nil
+		},
+	}
+
+	testService := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+	})
+	testService.SetHTTPClient(mockClient)
+	testService.SetRefundCheck(false)
+	testService.SetRetryPolicy(payment.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+	})
+
+	if _, err := testService.MerchantStatusInquiry(domain.StatusInquiryRequest{MerchantOid: "testorder123"}); err != nil {
+		t.Fatalf("MerchantStatusInquiry returned an error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+
+	calls = 0
+	_, err := testService.RefundPayment(domain.RefundRequest{
+		MerchantOid:  "testorder789",
+		ReturnAmount: domain.NewMoney(5000, "TRY"),
+	})
+	if err == nil {
+		t.Fatal("Expected RefundPayment to fail")
+	}
+	if calls != 1 {
+		t.Errorf("Expected refunds not to be retried, got %d attempts", calls)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	calls := 0
+	testService := setupTestService(&domain.PayTRResponse{Status: "success"})
+	testService.SetHTTPClient(&mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls++
+			return nil, errors.New("unexpected request")
+		},
+	})
+
+	req := domain.NewCardPaymentRequest{
+		CommonPaymentRequest: domain.CommonPaymentRequest{
+			UserIP:           "not-an-ip",
+			MerchantOid:      "order-123",
+			Email:            "invalid",
+			PaymentAmount:    domain.NewMoney(0, "TRY"),
+			UserBasket:       domain.NewBasket().Add("Product", domain.NewMoney(100, "TRY"), 1),
+			PaymentType:      "card",
+			Currency:         "XYZ",
+			TestMode:         "yes",
+			InstallmentCount: "24",
+			ClientLang:       "de",
+		},
+		CardOwner:   "John Doe",
+		CardNumber:  "4111111111111111",
+		ExpiryMonth: "13",
+		ExpiryYear:  testExpiryYear,
+		CVV:         "12",
+	}
+
+	_, err := testService.NewCardPayment(req)
+
+	var validationErr *payment.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	for _, field := range []string{"user_ip", "merchant_oid", "email", "payment_amount", "user_basket", "currency", "test_mode", "installment_count", "client_lang", "expiry_month", "cvv"} {
+		if !validationErr.Has(field) {
+			t.Errorf("Expected a problem with %s, got %v", field, validationErr)
+		}
+	}
+
+	var paytrErr *payment.Error
+	if !errors.As(err, &paytrErr) || paytrErr.Kind != payment.ErrorKindValidation {
+		t.Errorf("Expected an error of kind validation, got %v", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("Expected no request to be sent, got %d", calls)
+	}
+
+	_, err = testService.RefundPayment(domain.RefundRequest{MerchantOid: "testorder789"})
+	if !errors.As(err, &validationErr) || !validationErr.Has("return_amount") {
+		t.Errorf("Expected a return_amount problem, got %v", err)
+	}
+
+	_, err = testService.RefundPayment(domain.RefundRequest{MerchantOid: "testorder789", ReturnAmount: domain.NewMoney(100, "XYZ")})
+	if !errors.As(err, &validationErr) || !validationErr.Has("return_amount") {
+		t.Errorf("Expected a return_amount problem for an unknown currency, got %v", err)
+	}
+
+	_, err = testService.IFrameToken(domain.IFrameTokenRequest{
+		UserIP:        "127.0.0.1",
+		MerchantOid:   "testorder789",
+		Email:         "test@example.com",
+		PaymentAmount: domain.NewMoney(9990, "TL"),
+		UserBasket:    domain.NewBasket().Add("Product", domain.NewMoney(5000, "TL"), 1),
+		Currency:      "TL",
+		TestMode:      "1",
+	})
+	if !errors.As(err, &validationErr) || !validationErr.Has("user_basket") {
+		t.Errorf("Expected a user_basket problem, got %v", err)
+	}
+
+	_, err = testService.IFrameToken(domain.IFrameTokenRequest{
+		UserIP:         "127.0.0.1",
+		MerchantOid:    "test_order_789",
+		Email:          "not an email",
+		PaymentAmount:  domain.NewMoney(9990, "TL"),
+		UserBasket:     domain.NewBasket().Add("Product", domain.NewMoney(9990, "TL"), 1),
+		Currency:       "TL",
+		NoInstallment:  "yes",
+		MaxInstallment: "24",
+		TestMode:       "1",
+	})
+	for _, field := range []string{"merchant_oid", "email", "no_installment", "max_installment"} {
+		if !errors.As(err, &validationErr) || !validationErr.Has(field) {
+			t.Errorf("Expected a %s problem, got %v", field, err)
+		}
+	}
+
+	mismatch := simulatorPayment("order1", 10000)
+	mismatch.PaymentAmount = domain.NewMoney(10000, "EUR")
+	mismatch.UserBasket = domain.NewBasket().Add("Product", domain.NewMoney(10000, "EUR"), 1)
+	mismatch.Currency = "TL"
+	_, err = testService.NewCardPayment(mismatch)
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || !validationErr.Has("payment_amount") {
+		t.Errorf("Expected a payment_amount currency problem only, got %v", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("Expected no request to be sent, got %d", calls)
+	}
+}
@@ -0,0 +1,276 @@
+package payment_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamerd/paytr-go/config"
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+	"github.com/streamerd/paytr-go/paytrtest"
+)
+
+// setupSimulator starts a PayTR simulator and a service pointed at it
+func setupSimulator(t *testing.T) (*paytrtest.Server, payment.Service) {
+	t.Helper()
+
+	srv := paytrtest.NewServer(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+	})
+	t.Cleanup(srv.Close)
+
+	return srv, payment.NewService(srv.Config())
+}
+
+func simulatorPayment(merchantOid string, amount int64) domain.NewCardPaymentRequest {
+	return domain.NewCardPaymentRequest{
+		CommonPaymentRequest: domain.CommonPaymentRequest{
+			UserIP:           "127.0.0.1",
+			MerchantOid:      merchantOid,
+			Email:            "test@example.com",
+			PaymentAmount:    domain.NewMoney(amount, "TL"),
+			UserBasket:       domain.NewBasket().Add("Product", domain.NewMoney(amount, "TL"), 1),
+			PaymentType:      "card",
+			Currency:         "TL",
+			TestMode:         "1",
+			NonThreeD:        "0",
+			InstallmentCount: "0",
+		},
+		CardOwner:   "John Doe",
+		CardNumber:  "4111111111111111",
+		ExpiryMonth: "12",
+		ExpiryYear:  testExpiryYear,
+		CVV:         "123",
+	}
+}
+
+func TestSimulatorPaymentFlow(t *testing.T) {
+	srv, svc := setupSimulator(t)
+
+	var notifications []domain.PaymentNotification
+	callback := httptest.NewServer(payment.NewCallbackHandler(srv.Config(), func(n domain.PaymentNotification) error {
+		notifications = append(notifications, n)
+		return nil
+	}))
+	defer callback.Close()
+	srv.NotifyURL = callback.URL
+
+	req := simulatorPayment("order1", 10000)
+	req.StoreCard = "1"
+
+	resp, err := svc.NewCardPayment(req)
+	if err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+	if resp.Status != "success" {
+		t.Fatalf("Expected status 'success', got '%s' (%s)", resp.Status, resp.Message)
+	}
+
+	if len(notifications) != 1 || notifications[0].MerchantOid != "order1" || notifications[0].TotalAmount != "10000" {
+		t.Errorf("Unexpected notifications: %+v", notifications)
+	}
+
+	utoken, _ := resp.Data["utoken"].(string)
+	cards, err := svc.GetSavedCards(utoken)
+	if err != nil || len(cards) != 1 || cards[0].LastFour != "1111" || cards[0].Schema != "VISA" {
+		t.Fatalf("GetSavedCards failed: %v %+v", err, cards)
+	}
+	if len(srv.Cards(utoken)) != 1 {
+		t.Errorf("Expected 1 saved card, got %d", len(srv.Cards(utoken)))
+	}
+
+	refund, err := svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(4000, "TL")})
+	if err != nil || refund.Status != "success" {
+		t.Fatalf("RefundPayment failed: %v %+v", err, refund)
+	}
+
+	_, err = svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(7000, "TL")})
+	var paytrErr *payment.Error
+	if !errors.As(err, &paytrErr) || paytrErr.Kind != payment.ErrorKindValidation {
+		t.Errorf("Expected a validation error for a refund over the paid amount, got %v", err)
+	}
+
+	status, err := svc.MerchantStatusInquiry(domain.StatusInquiryRequest{MerchantOid: "order1"})
+	if err != nil {
+		t.Fatalf("MerchantStatusInquiry returned an error: %v", err)
+	}
+	if status.Status != "success" || status.PaymentAmount != "100.00" {
+		t.Errorf("Unexpected status inquiry response: %+v", status)
+	}
+
+	today := time.Now().In(domain.PayTRLocation).Format("2006-01-02")
+	details, err := svc.GetTransactionDetails(domain.TransactionDetailsRequest{
+		StartDate: today + " 00:00:00",
+		EndDate:   today + " 23:59:59",
+	})
+	if err != nil {
+		t.Fatalf("GetTransactionDetails returned an error: %v", err)
+	}
+	if len(details.Transactions) != 2 {
+		t.Fatalf("Expected a sale and a refund, got %+v", details.Transactions)
+	}
+	if details.Transactions[0].SiparisNo != "order1" || details.Transactions[0].IslemTutari != "100.00" {
+		t.Errorf("Unexpected transaction: %+v", details.Transactions[0])
+	}
+
+	// A period without transactions is reported as "failed", which is not an error
+	details, err = svc.GetTransactionDetails(domain.TransactionDetailsRequest{
+		StartDate: "2020-01-01 00:00:00",
+		EndDate:   "2020-01-01 23:59:59",
+	})
+	if err != nil || details.Status != "failed" || len(details.Transactions) != 0 {
+		t.Errorf("Expected no transactions for a quiet day, got %+v %v", details, err)
+	}
+	if _, err := svc.GetTransactionDetails(domain.TransactionDetailsRequest{StartDate: "yesterday", EndDate: "today"}); err == nil {
+		t.Error("Expected an error for invalid dates")
+	}
+
+	ctoken := srv.Cards(utoken)[0].CToken
+	deleted, err := svc.DeleteSavedCard(utoken, ctoken)
+	if err != nil || deleted.Status != "success" {
+		t.Fatalf("DeleteSavedCard failed: %v %+v", err, deleted)
+	}
+	if len(srv.Cards(utoken)) != 0 {
+		t.Error("Expected saved card to be deleted")
+	}
+}
+
+func TestSimulatorRejectsInvalidToken(t *testing.T) {
+	srv, _ := setupSimulator(t)
+
+	cfg := srv.Config()
+	cfg.MerchantKey = "wrong_key"
+	svc := payment.NewService(cfg)
+
+	_, err := svc.NewCardPayment(simulatorPayment("order2", 5000))
+	var paytrErr *payment.Error
+	if !errors.As(err, &paytrErr) || paytrErr.Kind != payment.ErrorKindAuth {
+		t.Errorf("Expected an auth error for a forged token, got %v", err)
+	}
+	if _, found := srv.Order("order2"); found {
+		t.Error("Expected no order to be recorded for a forged token")
+	}
+}
+
+func TestSimulatorDeclinedCard(t *testing.T) {
+	srv, svc := setupSimulator(t)
+	srv.Decline("4111111111111111", "Insufficient funds")
+
+	_, err := svc.NewCardPayment(simulatorPayment("order3", 5000))
+	var paytrErr *payment.Error
+	if !errors.As(err, &paytrErr) || paytrErr.Kind != payment.ErrorKindDeclined {
+		t.Errorf("Expected a declined error, got %v", err)
+	}
+	if paytrErr != nil && paytrErr.Message() != "Insufficient funds" {
+		t.Errorf("Expected message 'Insufficient funds', got '%s'", paytrErr.Message())
+	}
+
+	bin, err := svc.GetBinDetails("41111111")
+	if err != nil || bin.Brand != "VISA" || !bin.IsCredit() {
+		t.Errorf("Unexpected BIN details: %v %+v", err, bin)
+	}
+}
+
+// lostResponseClient forwards requests but drops the response of payment requests,
+// as if the connection timed out after PayTR processed the payment
+type lostResponseClient struct {
+	sendPayments bool
+}
+
+func (c *lostResponseClient) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != string(domain.EndpointPayment) {
+		return http.DefaultClient.Do(req)
+	}
+	if c.sendPayments {
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	return nil, context.DeadlineExceeded
+}
+
+func TestReconcileAmbiguousPayment(t *testing.T) {
+	_, svc := setupSimulator(t)
+	svc.SetHTTPClient(&lostResponseClient{sendPayments: true})
+	svc.SetReconcilePolicy(payment.ReconcilePolicy{MaxPolls: 3, Interval: time.Millisecond})
+
+	resp, err := svc.NewCardPayment(simulatorPayment("order4", 2500))
+	if err != nil {
+		t.Fatalf("Expected the payment to be reconciled, got %v", err)
+	}
+	if resp.Status != "success" || resp.Data["reconciled"] != true {
+		t.Errorf("Unexpected reconciled response: %+v", resp)
+	}
+}
+
+func TestReconcileUnsentPayment(t *testing.T) {
+	srv, svc := setupSimulator(t)
+	svc.SetHTTPClient(&lostResponseClient{sendPayments: false})
+	svc.SetReconcilePolicy(payment.ReconcilePolicy{MaxPolls: 2, Interval: time.Millisecond})
+
+	_, err := svc.NewCardPayment(simulatorPayment("order5", 2500))
+	var paytrErr *payment.Error
+	if !errors.As(err, &paytrErr) || !paytrErr.Retryable() {
+		t.Errorf("Expected the original timeout error, got %v", err)
+	}
+	if _, found := srv.Order("order5"); found {
+		t.Error("Expected no order to be recorded")
+	}
+}
+
+// hangingPaymentClient forwards requests, but waits for the request's context to be
+// done before reporting the outcome of payment requests, as a stalled connection would
+type hangingPaymentClient struct{}
+
+func (hangingPaymentClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || req.URL.Path != string(domain.EndpointPayment) {
+		return resp, err
+	}
+	resp.Body.Close()
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestReconcileAfterCallerDeadline(t *testing.T) {
+	_, svc := setupSimulator(t)
+	svc.SetHTTPClient(hangingPaymentClient{})
+	svc.SetReconcilePolicy(payment.ReconcilePolicy{MaxPolls: 3, Interval: 10 * time.Millisecond, Timeout: 5 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	resp, err := svc.NewCardPaymentContext(ctx, simulatorPayment("order6", 2500))
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("Expected the caller's deadline to expire, got %v", ctx.Err())
+	}
+	if err != nil {
+		t.Fatalf("Expected the payment to be reconciled after the deadline, got %v", err)
+	}
+	if resp.Status != "success" || resp.Data["reconciled"] != true {
+		t.Errorf("Unexpected reconciled response: %+v", resp)
+	}
+}
+
+func TestReconcileTimeout(t *testing.T) {
+	_, svc := setupSimulator(t)
+	svc.SetHTTPClient(&lostResponseClient{sendPayments: false})
+	svc.SetReconcilePolicy(payment.ReconcilePolicy{MaxPolls: 1000, Interval: 10 * time.Millisecond, Timeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := svc.NewCardPayment(simulatorPayment("order7", 2500))
+	var paytrErr *payment.Error
+	if !errors.As(err, &paytrErr) || !paytrErr.Retryable() {
+		t.Errorf("Expected the original timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected reconciliation to stop after its timeout, took %v", elapsed)
+	}
+}
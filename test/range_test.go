@@ -0,0 +1,180 @@
+package payment_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streamerd/paytr-go/config"
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+)
+
+// reportServer answers transaction report requests with one sale per day queried, dated at
+// the start of the window, and a transaction at the last second of May 1st, reported by the
+// windows whose inclusive bounds contain it. The window starting at fail gets an error, and
+// the one starting at empty PayTR's "failed" reply for a period without transactions.
+func reportServer(t *testing.T, fail, empty string) (*httptest.Server, func() int) {
+	var mu sync.Mutex
+	var starts []string
+	var inFlight, maxInFlight int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		start, end := r.PostForm.Get("start_date"), r.PostForm.Get("end_date")
+
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		starts = append(starts, start)
+		mu.Unlock()
+
+		switch start {
+		case fail:
+			w.Write([]byte(`{"status":"error","err_no":"003","err_msg":"Gecersiz tarih"}`))
+			return
+		case empty:
+			w.Write([]byte(`{"status":"failed"}`))
+			return
+		}
+		transactions := []domain.Transaction{
+			{IslemTipi: "Satis", IslemTutari: "10.00", IslemTarihi: start, SiparisNo: "order" + start[8:10]},
+		}
+		if boundary := "2024-05-01 23:59:59"; start <= boundary && boundary <= end {
+			transactions = append(transactions, domain.Transaction{IslemTipi: "Satis", IslemTutari: "1.00", IslemTarihi: boundary, SiparisNo: "boundary"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"status":       "success",
+				"transactions": transactions,
+			},
+		})
+	}))
+	t.Cleanup(func() {
+		server.Close()
+		if maxInFlight > 2 {
+			t.Errorf("Expected at most 2 concurrent requests, got %d", maxInFlight)
+		}
+	})
+	requests := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(starts)
+	}
+	return server, requests
+}
+
+func rangeService(server *httptest.Server) payment.Service {
+	svc := payment.NewService(config.PayTRConfig{
+		MerchantID:   "test_merchant",
+		MerchantKey:  "test_key",
+		MerchantSalt: "test_salt",
+		BaseURL:      server.URL,
+	})
+	svc.SetReportPolicy(payment.ReportPolicy{Window: 24 * time.Hour, Workers: 2})
+	return svc
+}
+
+func TestGetTransactionDetailsRange(t *testing.T) {
+	server, requests := reportServer(t, "", "")
+	svc := rangeService(server)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, domain.PayTRLocation)
+	to := time.Date(2024, 5, 6, 0, 0, 0, 0, domain.PayTRLocation)
+
+	var orders []string
+	for tx, err := range svc.GetTransactionDetailsRange(from.UTC(), to.UTC()) {
+		if err != nil {
+			t.Fatalf("GetTransactionDetailsRange returned an error: %v", err)
+		}
+		orders = append(orders, tx.SiparisNo)
+	}
+
+	expected := []string{"order01", "boundary", "order02", "order03", "order04", "order05"}
+	if len(orders) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, orders)
+	}
+	for i := range expected {
+		if orders[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, orders)
+			break
+		}
+	}
+	if requests() != 5 {
+		t.Errorf("Expected 5 requests, got %d", requests())
+	}
+}
+
+func TestGetTransactionDetailsRangeQuietDay(t *testing.T) {
+	server, _ := reportServer(t, "", "2024-05-02 00:00:00")
+	svc := rangeService(server)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, domain.PayTRLocation)
+	to := time.Date(2024, 5, 4, 0, 0, 0, 0, domain.PayTRLocation)
+
+	var orders []string
+	for tx, err := range svc.GetTransactionDetailsRange(from, to) {
+		if err != nil {
+			t.Fatalf("GetTransactionDetailsRange returned an error: %v", err)
+		}
+		orders = append(orders, tx.SiparisNo)
+	}
+	if len(orders) != 3 || orders[2] != "order03" {
+		t.Errorf("Expected the days around the quiet one, got %v", orders)
+	}
+}
+
+func TestGetTransactionDetailsRangeError(t *testing.T) {
+	server, _ := reportServer(t, "2024-05-02 00:00:00", "")
+	svc := rangeService(server)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, domain.PayTRLocation)
+	to := time.Date(2024, 5, 4, 0, 0, 0, 0, domain.PayTRLocation)
+
+	var count int
+	var rangeErr error
+	for _, err := range svc.GetTransactionDetailsRange(from, to) {
+		if err != nil {
+			rangeErr = err
+			break
+		}
+		count++
+	}
+
+	var paytrErr *payment.Error
+	if !errors.As(rangeErr, &paytrErr) {
+		t.Errorf("Expected a *payment.Error, got %v", rangeErr)
+	}
+	if count != 2 {
+		t.Errorf("Expected the first window's 2 transactions before the error, got %d", count)
+	}
+}
+
+func TestGetTransactionDetailsRangeStopsEarly(t *testing.T) {
+	server, requests := reportServer(t, "", "")
+	svc := rangeService(server)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, domain.PayTRLocation)
+	for range svc.GetTransactionDetailsRange(from, from.AddDate(0, 0, 30)) {
+		break
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if requests() >= 30 {
+		t.Errorf("Expected stopping early to cancel the remaining requests, got %d requests", requests())
+	}
+}
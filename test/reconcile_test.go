@@ -0,0 +1,84 @@
+package payment_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+	"github.com/streamerd/paytr-go/reconcile"
+	"github.com/streamerd/paytr-go/store"
+)
+
+func TestReconcileTransactions(t *testing.T) {
+	ctx := context.Background()
+	srv, svc := setupSimulator(t)
+	repo := store.NewMemory()
+	svc.SetPaymentRepository(repo)
+
+	pay := func(svc payment.Service, oid string, amount int64) {
+		req := simulatorPayment(oid, amount)
+		req.NonThreeD = "1"
+		if _, err := svc.NewCardPayment(req); err != nil {
+			t.Fatalf("NewCardPayment returned an error: %v", err)
+		}
+	}
+
+	// Matched: a sale and a partial refund
+	pay(svc, "order1", 10000)
+	if _, err := svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(2500, "TL")}); err != nil {
+		t.Fatalf("RefundPayment returned an error: %v", err)
+	}
+
+	// Missing locally: paid without recording
+	pay(payment.NewService(srv.Config()), "order2", 5000)
+
+	// Amount mismatch: recorded with a different amount
+	pay(svc, "order3", 7500)
+	recorded, _ := repo.FindPayment(ctx, "order3")
+	recorded.Amount = domain.NewMoney(7000, "TL")
+	repo.SavePayment(ctx, recorded)
+
+	// Missing at PayTR: recorded as succeeded but never sent
+	now := time.Now()
+	ghost := domain.NewPayment("order4", domain.NewMoney(1000, "TL"), now)
+	ghost.Transition(domain.PaymentStatusSucceeded, now, "")
+	repo.SavePayment(ctx, ghost)
+
+	// The two hours are reported in four windows of half an hour
+	var reports atomic.Int32
+	svc.SetReportPolicy(payment.ReportPolicy{Window: 30 * time.Minute, Workers: 2})
+	svc.SetHTTPClient(&mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == string(domain.EndpointTransactionDetails) {
+			reports.Add(1)
+		}
+		return http.DefaultClient.Do(req)
+	}})
+
+	report, err := reconcile.New(svc, repo).Reconcile(ctx, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Reconcile returned an error: %v", err)
+	}
+
+	if report.OK() {
+		t.Error("Expected the report to show differences")
+	}
+	if len(report.Matched) != 2 || report.Matched[0].PayTR.MerchantOid != "order1" || report.Matched[1].Local.Kind != reconcile.KindRefund {
+		t.Errorf("Unexpected matches: %+v", report.Matched)
+	}
+	if len(report.MissingLocally) != 1 || report.MissingLocally[0].MerchantOid != "order2" || report.MissingLocally[0].Payment != nil {
+		t.Errorf("Unexpected entries missing locally: %+v", report.MissingLocally)
+	}
+	if len(report.AmountMismatches) != 1 || report.AmountMismatches[0].PayTR.Amount.Minor != 7500 || report.AmountMismatches[0].Local.Amount.Minor != 7000 {
+		t.Errorf("Unexpected amount mismatches: %+v", report.AmountMismatches)
+	}
+	if len(report.MissingAtPayTR) != 1 || report.MissingAtPayTR[0].MerchantOid != "order4" {
+		t.Errorf("Unexpected entries missing at PayTR: %+v", report.MissingAtPayTR)
+	}
+	if n := reports.Load(); n != 4 {
+		t.Errorf("Expected 4 report requests, got %d", n)
+	}
+}
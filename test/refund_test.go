@@ -0,0 +1,116 @@
+package payment_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+)
+
+func TestParseReturns(t *testing.T) {
+	returns := `[{"return_amount":"40.00","return_date":"2024-05-01 14:30:00","return_type":"iade","return_status":"success","reference_no":"R1","return_source":"api"},` +
+		`{"return_amount":15.5,"return_date":"2024-05-02 09:00:00","return_status":"failed"}]`
+
+	records, err := domain.ParseReturns(returns, "TL")
+	if err != nil {
+		t.Fatalf("ParseReturns returned an error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 refunds, got %+v", records)
+	}
+	if records[0].Amount.Minor != 4000 || records[0].ReferenceNo != "R1" || records[0].RequestedAt.Day() != 1 {
+		t.Errorf("Unexpected first refund: %+v", records[0])
+	}
+	if records[1].Amount.Minor != 1550 || !records[1].Failed() {
+		t.Errorf("Unexpected second refund: %+v", records[1])
+	}
+
+	byIndex, err := domain.ParseReturns(`{"1":{"return_amount":"2"},"0":{"return_amount":"1"}}`, "TL")
+	if err != nil || len(byIndex) != 2 || byIndex[0].Amount.Minor != 100 {
+		t.Errorf("Unexpected refunds keyed by index: %v %+v", err, byIndex)
+	}
+
+	if records, err := domain.ParseReturns("", "TL"); err != nil || len(records) != 0 {
+		t.Errorf("Expected no refunds for an empty value, got %v %+v", err, records)
+	}
+	if _, err := domain.ParseReturns("not json", "TL"); err == nil {
+		t.Error("Expected an error for an invalid value")
+	}
+
+	summary, err := domain.StatusInquiryResponse{
+		Status:        "success",
+		PaymentAmount: "100.00",
+		Currency:      "TL",
+		Returns:       returns,
+	}.RefundSummary("order1")
+	if err != nil {
+		t.Fatalf("RefundSummary returned an error: %v", err)
+	}
+	if summary.Refunded.Minor != 4000 || summary.Remaining.Minor != 6000 {
+		t.Errorf("Expected 40.00 refunded and 60.00 remaining, got %s and %s", summary.Refunded, summary.Remaining)
+	}
+}
+
+func TestSimulatorRefundCheck(t *testing.T) {
+	srv, svc := setupSimulator(t)
+
+	if _, err := svc.NewCardPayment(simulatorPayment("order1", 10000)); err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+
+	first, err := svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(3000, "TL")})
+	if err != nil {
+		t.Fatalf("RefundPayment returned an error: %v", err)
+	}
+	second, err := svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(2000, "TL")})
+	if err != nil {
+		t.Fatalf("RefundPayment returned an error: %v", err)
+	}
+
+	order, _ := srv.Order("order1")
+	if len(order.Refunds) != 2 || order.Refunds[0].ReferenceNo == "" || order.Refunds[0].ReferenceNo == order.Refunds[1].ReferenceNo {
+		t.Errorf("Expected two refunds with distinct generated reference numbers, got %+v", order.Refunds)
+	}
+	if first.Data["reference_no"] != order.Refunds[0].ReferenceNo || second.Data["reference_no"] != order.Refunds[1].ReferenceNo {
+		t.Errorf("Expected the reference numbers in the responses, got %v and %v", first.Data, second.Data)
+	}
+
+	summary, err := svc.GetRefunds("order1")
+	if err != nil {
+		t.Fatalf("GetRefunds returned an error: %v", err)
+	}
+	if len(summary.Refunds) != 2 || summary.Refunded.String() != "50.00" || summary.Remaining.String() != "50.00" {
+		t.Errorf("Unexpected refund summary: %+v", summary)
+	}
+
+	_, err = svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(6000, "TL")})
+	if !errors.Is(err, payment.ErrRefundExceedsRemaining) {
+		t.Errorf("Expected ErrRefundExceedsRemaining, got %v", err)
+	}
+	if order, _ := srv.Order("order1"); len(order.Refunds) != 2 {
+		t.Errorf("Expected the over-refund not to be sent, got %+v", order.Refunds)
+	}
+
+	_, err = svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(1000, "USD")})
+	var verr *payment.ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Field != "return_amount" {
+		t.Errorf("Expected a return_amount error for a refund in another currency, got %v", err)
+	}
+	if order, _ := srv.Order("order1"); len(order.Refunds) != 2 {
+		t.Errorf("Expected the refund in another currency not to be sent, got %+v", order.Refunds)
+	}
+
+	if _, err := svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(5000, "TL"), ReferenceNo: "final1"}); err != nil {
+		t.Fatalf("RefundPayment of the remaining amount returned an error: %v", err)
+	}
+	if order, _ := srv.Order("order1"); order.Refunds[2].ReferenceNo != "final1" {
+		t.Errorf("Expected the given reference number to be kept, got %q", order.Refunds[2].ReferenceNo)
+	}
+
+	svc.SetRefundCheck(false)
+	_, err = svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(1000, "TL")})
+	if err == nil || errors.Is(err, payment.ErrRefundExceedsRemaining) {
+		t.Errorf("Expected the over-refund to be sent and rejected by PayTR, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package payment_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/report"
+)
+
+var reportTransactions = []domain.Transaction{
+	{
+		IslemTipi:     "Satis",
+		NetTutar:      "1213.54",
+		KesintiTutari: "21.46",
+		KesintiOrani:  "1.74",
+		IslemTutari:   "1235.00",
+		OdemeTutari:   "1235.00",
+		IslemTarihi:   "2024-05-01 14:30:00",
+		ParaBirimi:    "TL",
+		Taksit:        "0",
+		KartMarka:     "WORLD",
+		SiparisNo:     "order1",
+	},
+	{
+		IslemTipi:   "Iade",
+		NetTutar:    "-100.00",
+		IslemTutari: "100.00",
+		IslemTarihi: "2024-05-02 09:00:00",
+		ParaBirimi:  "TL",
+		SiparisNo:   "order1",
+	},
+}
+
+func TestWriteTransactionsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := report.WriteTransactions(&buf, report.FormatCSV, report.Options{
+		Columns: []string{"kind", "merchant_oid", "occurred_at", "net_amount", "fee_rate", "installments"},
+	}, reportTransactions)
+	if err != nil {
+		t.Fatalf("WriteTransactions returned an error: %v", err)
+	}
+
+	expected := "Type,Order No,Date,Net Amount,Fee Rate,Installments\n" +
+		"sale,order1,2024-05-01 14:30:00,1213.54,1.74,1\n" +
+		"refund,order1,2024-05-02 09:00:00,-100.00,0.00,1\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteTransactionsTurkishCSV(t *testing.T) {
+	var buf bytes.Buffer
+	opts := report.TurkishOptions
+	opts.Columns = []string{"kind", "occurred_at", "amount", "fee_rate"}
+	if err := report.WriteTransactions(&buf, report.FormatCSV, opts, reportTransactions[:1]); err != nil {
+		t.Fatalf("WriteTransactions returned an error: %v", err)
+	}
+
+	expected := "İşlem Tipi;İşlem Tarihi;İşlem Tutarı;Kesinti Oranı\n" +
+		"Satış;01.05.2024 14:30:00;1.235,00;1,74\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteTransactionsJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	err := report.WriteTransactions(&buf, report.FormatJSONL, report.Options{
+		Columns: []string{"merchant_oid", "kind", "amount", "installments"},
+	}, reportTransactions)
+	if err != nil {
+		t.Fatalf("WriteTransactions returned an error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := `{"merchant_oid":"order1","kind":"sale","amount":"1235.00","installments":1}`
+	if len(lines) != 2 || lines[0] != expected {
+		t.Errorf("Expected %s, got %v", expected, lines)
+	}
+}
+
+func TestWriteStatuses(t *testing.T) {
+	var buf bytes.Buffer
+	err := report.WriteStatuses(&buf, report.FormatCSV, report.Options{
+		Columns:  []string{"merchant_oid", "status", "total", "test_mode"},
+		NoHeader: true,
+	}, []report.StatusRecord{
+		{MerchantOid: "order1", Response: domain.StatusInquiryResponse{Status: "success", PaymentTotal: "103.5", Currency: "TL", TestMode: "1"}},
+	})
+	if err != nil {
+		t.Fatalf("WriteStatuses returned an error: %v", err)
+	}
+	if buf.String() != "order1,success,103.50,1\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+}
+
+func TestReportOptionErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report.WriteTransactions(&buf, report.FormatCSV, report.Options{Columns: []string{"nope"}}, nil); err == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+	if err := report.WriteTransactions(&buf, "xlsx", report.Options{}, nil); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
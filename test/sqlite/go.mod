@@ -0,0 +1,10 @@
+module github.com/streamerd/paytr-go/test/sqlite
+
+go 1.23
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/streamerd/paytr-go v0.0.0
+)
+
+replace github.com/streamerd/paytr-go => ../..
@@ -0,0 +1,155 @@
+// The store.SQL tests run against SQLite in a module of their own, so that the cgo
+// SQLite driver does not become a dependency of paytr-go.
+package payment_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/store"
+)
+
+// setupSQLStore opens a fresh SQLite database and migrates it
+func setupSQLStore(t *testing.T) (*sql.DB, *store.SQL) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "paytr.db"))
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo := store.NewSQL(db)
+	if err := repo.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate returned an error: %v", err)
+	}
+	return db, repo
+}
+
+func TestSQLMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, repo := setupSQLStore(t)
+
+	var applied int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM paytr_schema_migrations`).Scan(&applied); err != nil || applied == 0 {
+		t.Fatalf("Expected migrations to be recorded, got %d %v", applied, err)
+	}
+
+	if err := repo.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate on a migrated database returned an error: %v", err)
+	}
+	var again int
+	db.QueryRowContext(ctx, `SELECT COUNT(*) FROM paytr_schema_migrations`).Scan(&again)
+	if again != applied {
+		t.Errorf("Expected no migration to be applied twice, got %d then %d", applied, again)
+	}
+}
+
+func TestSQLPaymentRepository(t *testing.T) {
+	ctx := context.Background()
+	_, repo := setupSQLStore(t)
+
+	if _, err := repo.FindPayment(ctx, "missing"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	created := time.Date(2024, 5, 1, 14, 30, 0, 123456789, time.UTC)
+	payment := domain.NewPayment("order1", domain.NewMoney(10000, "TL"), created)
+	payment.UserID = "user1"
+	payment.PaymentMethod = domain.PaymentMethodCard
+	if err := repo.SavePayment(ctx, payment); err != nil {
+		t.Fatalf("SavePayment returned an error: %v", err)
+	}
+
+	loaded, err := repo.FindPayment(ctx, "order1")
+	if err != nil {
+		t.Fatalf("FindPayment returned an error: %v", err)
+	}
+	if loaded.ID != "order1" || loaded.UserID != "user1" || loaded.Amount != payment.Amount || loaded.Currency != "TL" ||
+		loaded.Status != domain.PaymentStatusCreated || loaded.PaymentMethod != domain.PaymentMethodCard ||
+		!loaded.CreatedAt.Equal(created) || !loaded.UpdatedAt.Equal(created) || len(loaded.History) != 1 {
+		t.Errorf("Unexpected payment after a round trip: %+v", loaded)
+	}
+
+	// Saving again updates the existing row and replaces its history.
+	paid := created.Add(time.Minute)
+	loaded.Transition(domain.PaymentStatusSucceeded, paid, "payment response")
+	loaded.Refund(domain.NewMoney(2500, "TL"), paid.Add(time.Hour), "refund")
+	loaded.Refund(domain.NewMoney(1500, "TL"), paid.Add(2*time.Hour), "refund")
+	stale, _ := repo.FindPayment(ctx, "order1")
+	if err := repo.SavePayment(ctx, loaded); err != nil {
+		t.Fatalf("SavePayment over an existing payment returned an error: %v", err)
+	}
+	if err := repo.SavePayment(ctx, stale); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a stale payment, got %v", err)
+	}
+	if err := repo.SavePayment(ctx, domain.NewPayment("order1", domain.NewMoney(100, "TL"), created)); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a new payment with a taken merchant_oid, got %v", err)
+	}
+
+	updated, err := repo.FindPayment(ctx, "order1")
+	if err != nil {
+		t.Fatalf("FindPayment returned an error: %v", err)
+	}
+	if updated.Status != domain.PaymentStatusPartiallyRefunded || updated.RefundedAmount != domain.NewMoney(4000, "TL") ||
+		!updated.CreatedAt.Equal(created) || !updated.UpdatedAt.Equal(paid.Add(2*time.Hour)) {
+		t.Errorf("Unexpected payment after an update: %+v", updated)
+	}
+
+	want := []domain.StatusChange{
+		{To: domain.PaymentStatusCreated, At: created},
+		{From: domain.PaymentStatusCreated, To: domain.PaymentStatusSucceeded, At: paid, Reason: "payment response"},
+		{From: domain.PaymentStatusSucceeded, To: domain.PaymentStatusPartiallyRefunded, At: paid.Add(time.Hour), Reason: "refund", Amount: domain.NewMoney(2500, "TL")},
+		{From: domain.PaymentStatusPartiallyRefunded, To: domain.PaymentStatusPartiallyRefunded, At: paid.Add(2 * time.Hour), Reason: "refund", Amount: domain.NewMoney(1500, "TL")},
+	}
+	if len(updated.History) != len(want) {
+		t.Fatalf("Expected %d status changes, got %+v", len(want), updated.History)
+	}
+	for i, change := range updated.History {
+		if change.From != want[i].From || change.To != want[i].To || !change.At.Equal(want[i].At) ||
+			change.Reason != want[i].Reason || change.Amount != want[i].Amount {
+			t.Errorf("Status change %d: expected %+v, got %+v", i, want[i], change)
+		}
+	}
+
+	other := domain.NewPayment("order2", domain.NewMoney(5000, "TL"), created.Add(time.Second))
+	other.UserID = "user1"
+	repo.SavePayment(ctx, other)
+
+	payments, err := repo.ListPayments(ctx, "user1")
+	if err != nil || len(payments) != 2 || payments[0].MerchantOid != "order1" || payments[1].MerchantOid != "order2" {
+		t.Errorf("Expected the payments of user1 by creation time, got %v %+v", err, payments)
+	}
+	since, err := repo.ListPaymentsUpdatedSince(ctx, paid)
+	if err != nil || len(since) != 1 || since[0].MerchantOid != "order1" || len(since[0].History) != 4 {
+		t.Errorf("Expected the payment updated since %v, got %v %+v", paid, err, since)
+	}
+}
+
+func TestSQLCardRepository(t *testing.T) {
+	ctx := context.Background()
+	_, repo := setupSQLStore(t)
+
+	repo.SaveCard(ctx, &domain.SavedCard{UToken: "u1", CToken: "c1", LastFour: "1111", CreatedAt: time.Unix(1, 0)})
+	repo.SaveCard(ctx, &domain.SavedCard{UToken: "u1", CToken: "c2", LastFour: "2222", CreatedAt: time.Unix(2, 0)})
+	repo.SaveCard(ctx, &domain.SavedCard{UToken: "u1", CToken: "c1", LastFour: "1111", Schema: "VISA", RequireCVV: true})
+
+	cards, err := repo.ListCards(ctx, "u1")
+	if err != nil || len(cards) != 2 || cards[0].CToken != "c1" || cards[0].Schema != "VISA" || !cards[0].RequireCVV {
+		t.Errorf("Unexpected cards: %v %+v", err, cards)
+	}
+
+	if err := repo.DeleteCard(ctx, "u1", "c1"); err != nil {
+		t.Fatalf("DeleteCard returned an error: %v", err)
+	}
+	if err := repo.DeleteCard(ctx, "u1", "c1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package payment_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/store"
+)
+
+func TestMemoryPaymentRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemory()
+
+	if _, err := repo.FindPayment(ctx, "missing"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	for _, oid := range []string{"order2", "order1"} {
+		if err := repo.SavePayment(ctx, &domain.Payment{MerchantOid: oid, UserID: "user1", Status: domain.PaymentStatusCreated}); err != nil {
+			t.Fatalf("SavePayment returned an error: %v", err)
+		}
+	}
+	if err := repo.SavePayment(ctx, &domain.Payment{MerchantOid: "order2", UserID: "user1", Status: domain.PaymentStatusSucceeded}); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a new payment with a taken merchant_oid, got %v", err)
+	}
+
+	stale, _ := repo.FindPayment(ctx, "order2")
+	payment, _ := repo.FindPayment(ctx, "order2")
+	payment.Status = domain.PaymentStatusSucceeded
+	if err := repo.SavePayment(ctx, payment); err != nil || payment.Version != 2 {
+		t.Fatalf("SavePayment returned an error: %v, version %d", err, payment.Version)
+	}
+	if err := repo.SavePayment(ctx, stale); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a stale payment, got %v", err)
+	}
+
+	payment, err := repo.FindPayment(ctx, "order2")
+	if err != nil || payment.Status != domain.PaymentStatusSucceeded || payment.ID != "order2" {
+		t.Errorf("Unexpected payment: %v %+v", err, payment)
+	}
+
+	payments, _ := repo.ListPayments(ctx, "user1")
+	if len(payments) != 2 || payments[0].MerchantOid != "order2" || payments[1].MerchantOid != "order1" {
+		t.Errorf("Expected payments in insertion order, got %+v", payments)
+	}
+}
+
+func TestMemoryCardRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemory()
+
+	repo.SaveCard(ctx, &domain.SavedCard{UToken: "u1", CToken: "c1", LastFour: "1111"})
+	repo.SaveCard(ctx, &domain.SavedCard{UToken: "u1", CToken: "c2", LastFour: "2222"})
+	repo.SaveCard(ctx, &domain.SavedCard{UToken: "u2", CToken: "c3", LastFour: "3333"})
+
+	cards, _ := repo.ListCards(ctx, "u1")
+	if len(cards) != 2 || cards[0].LastFour != "1111" || cards[1].LastFour != "2222" {
+		t.Errorf("Unexpected cards: %+v", cards)
+	}
+
+	if err := repo.DeleteCard(ctx, "u1", "c1"); err != nil {
+		t.Fatalf("DeleteCard returned an error: %v", err)
+	}
+	if err := repo.DeleteCard(ctx, "u1", "c1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if cards, _ := repo.ListCards(ctx, "u1"); len(cards) != 1 {
+		t.Errorf("Expected 1 card, got %d", len(cards))
+	}
+}
+
+func TestServiceRecordsPayments(t *testing.T) {
+	ctx := context.Background()
+	srv, svc := setupSimulator(t)
+	repo := store.NewMemory()
+	svc.SetPaymentRepository(repo)
+	svc.SetCardRepository(repo)
+
+	req := simulatorPayment("order1", 10000)
+	req.StoreCard = "1"
+	req.NonThreeD = "1"
+	resp, err := svc.NewCardPayment(req)
+	if err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+
+	payment, err := repo.FindPayment(ctx, "order1")
+	if err != nil {
+		t.Fatalf("Expected the payment to be recorded: %v", err)
+	}
+	if payment.Status != domain.PaymentStatusSucceeded || payment.Amount.Minor != 10000 ||
+		payment.PaymentMethod != domain.PaymentMethodCard || payment.UserID != "test@example.com" {
+		t.Errorf("Unexpected payment: %+v", payment)
+	}
+
+	utoken, _ := resp.Data["utoken"].(string)
+	cards, _ := repo.ListCards(ctx, utoken)
+	if len(cards) != 1 || cards[0].LastFour != "1111" || cards[0].UserID != "test@example.com" {
+		t.Fatalf("Unexpected recorded cards: %+v", cards)
+	}
+
+	if _, err := svc.GetSavedCards(utoken); err != nil {
+		t.Fatalf("GetSavedCards returned an error: %v", err)
+	}
+	cards, _ = repo.ListCards(ctx, utoken)
+	if len(cards) != 1 || cards[0].Schema != "VISA" || cards[0].UserID != "test@example.com" {
+		t.Errorf("Expected the recorded card to be updated, got %+v", cards)
+	}
+
+	if _, err := svc.DeleteSavedCard(utoken, cards[0].CToken); err != nil {
+		t.Fatalf("DeleteSavedCard returned an error: %v", err)
+	}
+	if cards, _ := repo.ListCards(ctx, utoken); len(cards) != 0 {
+		t.Errorf("Expected the card to be forgotten, got %+v", cards)
+	}
+
+	if _, err := svc.RefundPayment(domain.RefundRequest{MerchantOid: "order1", ReturnAmount: domain.NewMoney(10000, "TL")}); err != nil {
+		t.Fatalf("RefundPayment returned an error: %v", err)
+	}
+	if payment, _ := repo.FindPayment(ctx, "order1"); payment.Status != domain.PaymentStatusRefunded || payment.RefundedAmount.Minor != 10000 {
+		t.Errorf("Expected status 'refunded', got '%s'", payment.Status)
+	}
+
+	srv.Decline("4111111111111111", "Insufficient funds")
+	svc.NewCardPayment(simulatorPayment("order2", 5000))
+	if payment, _ := repo.FindPayment(ctx, "order2"); payment == nil || payment.Status != domain.PaymentStatusFailed {
+		t.Errorf("Expected the declined payment to be recorded as failed, got %+v", payment)
+	}
+
+	invalid := simulatorPayment("order3", 5000)
+	invalid.Email = "not an email"
+	svc.NewCardPayment(invalid)
+	if _, err := repo.FindPayment(ctx, "order3"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected invalid requests not to be recorded, got %v", err)
+	}
+}
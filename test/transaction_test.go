@@ -0,0 +1,112 @@
+package payment_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+)
+
+func TestTransactionView(t *testing.T) {
+	tx := domain.Transaction{
+		IslemTipi:     "Satış",
+		NetTutar:      "1.213,54",
+		KesintiTutari: "21,46 TL",
+		KesintiOrani:  "%1,74",
+		IslemTutari:   "1.235,00",
+		OdemeTutari:   "1.260,00",
+		IslemTarihi:   "01.05.2024 14:30",
+		ParaBirimi:    "TL",
+		Taksit:        "3 Taksit",
+		KartMarka:     "WORLD",
+		KartNo:        "411111******1111",
+		SiparisNo:     "order1",
+		OdemeTipi:     "card",
+	}
+
+	v, err := tx.View()
+	if err != nil {
+		t.Fatalf("View returned an error: %v", err)
+	}
+
+	if v.Kind != domain.TransactionKindSale {
+		t.Errorf("Expected kind 'sale', got '%s'", v.Kind)
+	}
+	if v.NetAmount != domain.NewMoney(121354, "TL") || v.FeeAmount != domain.NewMoney(2146, "TL") {
+		t.Errorf("Unexpected amounts: net %v, fee %v", v.NetAmount, v.FeeAmount)
+	}
+	if v.Amount.Minor != 123500 || v.PaidAmount.Minor != 126000 {
+		t.Errorf("Unexpected amounts: %v, paid %v", v.Amount, v.PaidAmount)
+	}
+	if v.FeeRate != 1.74 {
+		t.Errorf("Expected fee rate 1.74, got %v", v.FeeRate)
+	}
+	expected := time.Date(2024, 5, 1, 14, 30, 0, 0, domain.PayTRLocation)
+	if !v.OccurredAt.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, v.OccurredAt)
+	}
+	if v.Installments != 3 || v.CardBrand != "WORLD" || v.Raw != tx {
+		t.Errorf("Unexpected view: %+v", v)
+	}
+}
+
+func TestTransactionViewFormats(t *testing.T) {
+	tests := []struct {
+		islemTipi    string
+		taksit       string
+		tarih        string
+		kind         domain.TransactionKind
+		installments int
+	}{
+		{"Iade", "0", "2024-05-01 14:30:00", domain.TransactionKindRefund, 1},
+		{"S", "", "2024-05-01T14:30:00", domain.TransactionKindSale, 1},
+		{"Chargeback", "Tek Çekim", "2024-05-01 14:30", domain.TransactionKindOther, 1},
+		{"satis", "12", "01.05.2024 14:30:00", domain.TransactionKindSale, 12},
+	}
+
+	for _, tt := range tests {
+		v, err := domain.Transaction{IslemTipi: tt.islemTipi, Taksit: tt.taksit, IslemTarihi: tt.tarih}.View()
+		if err != nil {
+			t.Errorf("View(%q) returned an error: %v", tt.tarih, err)
+			continue
+		}
+		if v.Kind != tt.kind || v.Installments != tt.installments {
+			t.Errorf("Expected %s with %d installments, got %s with %d", tt.kind, tt.installments, v.Kind, v.Installments)
+		}
+		if v.OccurredAt.Hour() != 14 || v.OccurredAt.Minute() != 30 || !v.NetAmount.IsZero() {
+			t.Errorf("Unexpected view of %q: %+v", tt.tarih, v)
+		}
+	}
+
+	if _, err := (domain.Transaction{IslemTarihi: "yesterday"}).View(); err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+	if _, err := (domain.Transaction{IslemTarihi: "2024-05-01", NetTutar: "1-2"}).View(); err == nil {
+		t.Error("Expected an error for an invalid amount")
+	}
+}
+
+func TestStatusInquiryView(t *testing.T) {
+	resp := domain.StatusInquiryResponse{
+		Status:        "success",
+		PaymentAmount: "100.00",
+		PaymentTotal:  "103.50",
+		Currency:      "TL",
+		NetTutar:      "98.10",
+		KesintiTutari: "1.90",
+		Taksit:        "2",
+		KartMarka:     "BONUS",
+		TestMode:      "1",
+	}
+
+	v, err := resp.View()
+	if err != nil {
+		t.Fatalf("View returned an error: %v", err)
+	}
+	if v.Amount.Minor != 10000 || v.Total.Minor != 10350 || v.NetAmount.Minor != 9810 || v.FeeAmount.Minor != 190 {
+		t.Errorf("Unexpected amounts: %+v", v)
+	}
+	if v.Installments != 2 || v.CardBrand != "BONUS" || !v.TestMode || !v.PaidAt.IsZero() {
+		t.Errorf("Unexpected view: %+v", v)
+	}
+}
@@ -0,0 +1,139 @@
+package payment_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/streamerd/paytr-go/domain"
+	"github.com/streamerd/paytr-go/payment"
+)
+
+const testIBAN = "TR33 0006 1005 1978 6457 8413 26"
+
+func TestSimulatorPlatformTransfer(t *testing.T) {
+	srv, svc := setupSimulator(t)
+
+	var results []domain.TransferNotification
+	callback := httptest.NewServer(payment.NewTransferCallbackHandler(srv.Config(), func(n domain.TransferNotification) error {
+		results = append(results, n)
+		return nil
+	}))
+	defer callback.Close()
+	srv.TransferNotifyURL = callback.URL
+
+	if _, err := svc.NewCardPayment(simulatorPayment("order1", 10000)); err != nil {
+		t.Fatalf("NewCardPayment returned an error: %v", err)
+	}
+
+	transfer := domain.PlatformTransferRequest{
+		MerchantOid:       "order1",
+		TransID:           "transfer1",
+		SubmerchantAmount: domain.NewMoney(8500, "TL"),
+		TotalAmount:       domain.NewMoney(10000, "TL"),
+		TransferName:      "Test Seller Ltd",
+		TransferIBAN:      testIBAN,
+	}
+	resp, err := svc.PlatformTransfer(transfer)
+	if err != nil || resp.Status != "success" {
+		t.Fatalf("PlatformTransfer failed: %v %+v", err, resp)
+	}
+
+	recorded, ok := srv.Transfer("transfer1")
+	if !ok || recorded.SubmerchantAmount.Minor != 8500 || recorded.TransferIBAN != "TR330006100519786457841326" {
+		t.Errorf("Unexpected transfer at the simulator: %+v", recorded)
+	}
+	if len(results) != 1 || len(results[0].TransIDs) != 1 || results[0].TransIDs[0] != "transfer1" {
+		t.Errorf("Unexpected transfer results: %+v", results)
+	}
+
+	transfer.TransID = "transfer2"
+	transfer.SubmerchantAmount = domain.NewMoney(2000, "TL")
+	if _, err := svc.PlatformTransfer(transfer); err == nil {
+		t.Error("Expected an error for transfers exceeding the order amount")
+	}
+
+	srv.ReturnTransfer("transfer1")
+	now := time.Now()
+	returned, err := svc.GetReturnedTransfers(domain.ReturnedTransfersRequest{
+		StartDate: now.Add(-time.Hour),
+		EndDate:   now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("GetReturnedTransfers returned an error: %v", err)
+	}
+	if len(returned) != 1 || returned[0].TransferAmount.String() != "85.00" || returned[0].TransferAmount.Currency != "TL" {
+		t.Errorf("Unexpected returned transfers: %+v", returned)
+	}
+
+	returned, err = svc.GetReturnedTransfers(domain.ReturnedTransfersRequest{
+		StartDate: now.Add(-2 * time.Hour),
+		EndDate:   now.Add(-time.Hour),
+	})
+	if err != nil || len(returned) != 0 {
+		t.Errorf("Expected no returned transfers before the return, got %+v %v", returned, err)
+	}
+
+	_, err = svc.GetReturnedTransfers(domain.ReturnedTransfersRequest{StartDate: now, EndDate: now})
+	var perr *payment.Error
+	if !errors.As(err, &perr) || perr.Kind != payment.ErrorKindValidation {
+		t.Errorf("Expected a validation error for an empty period, got %v", err)
+	}
+}
+
+func TestValidatePlatformTransfer(t *testing.T) {
+	err := payment.ValidatePlatformTransfer(domain.PlatformTransferRequest{
+		MerchantOid:       "order1",
+		TransID:           "transfer-1",
+		SubmerchantAmount: domain.NewMoney(20000, "TL"),
+		TotalAmount:       domain.NewMoney(10000, "TL"),
+		TransferIBAN:      "TR330006100519786457841327",
+	})
+
+	var validationErr *payment.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	for _, field := range []string{"trans_id", "submerchant_amount", "transfer_name", "transfer_iban"} {
+		if !validationErr.Has(field) {
+			t.Errorf("Expected an error for %s, got %v", field, err)
+		}
+	}
+	if validationErr.Has("merchant_oid") {
+		t.Errorf("Did not expect an error for merchant_oid, got %v", err)
+	}
+
+	err = payment.ValidatePlatformTransfer(domain.PlatformTransferRequest{
+		MerchantOid:       "order1",
+		TransID:           "transfer1",
+		SubmerchantAmount: domain.NewMoney(5000, "USD"),
+		TotalAmount:       domain.NewMoney(10000, "TL"),
+		TransferName:      "Seller Ltd",
+		TransferIBAN:      payment.NormalizeIBAN(testIBAN),
+	})
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || !validationErr.Has("submerchant_amount") {
+		t.Errorf("Expected a submerchant_amount error for mismatched currencies, got %v", err)
+	}
+}
+
+func TestTransferCallbackHandlerRejectsForgedHash(t *testing.T) {
+	called := false
+	handler := payment.NewTransferCallbackHandler(callbackConfig, func(n domain.TransferNotification) error {
+		called = true
+		return nil
+	})
+
+	rec := postNotification(handler, url.Values{
+		"trans_ids": {`["transfer1"]`},
+		"hash":      {notificationHash("transfer1", "", "")},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+	if called {
+		t.Error("Callback should not be called for a forged hash")
+	}
+}